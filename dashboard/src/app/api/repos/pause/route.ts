@@ -1,10 +1,27 @@
 import { NextResponse } from "next/server";
+import { Kafka } from "kafkajs";
 import { db } from "@/lib/db";
 import { repositories } from "@/lib/schema";
 import { eq, and } from "drizzle-orm";
 import { getServerSession } from "next-auth";
 import { authOptions } from "@/lib/auth";
 
+const kafka = new Kafka({
+  clientId: "sentinel-dashboard",
+  brokers: [process.env.KAFKA_BROKER || "localhost:9092"],
+  retry: { retries: 3 },
+});
+
+const producer = kafka.producer();
+let producerReady = false;
+
+async function ensureProducer() {
+  if (!producerReady) {
+    await producer.connect();
+    producerReady = true;
+  }
+}
+
 export async function GET() {
   const session = await getServerSession(authOptions);
   const userIdentifier = session?.user?.email || (session?.user as any)?.login;
@@ -58,6 +75,22 @@ export async function PATCH(request: Request) {
       return NextResponse.json({ error: "Repository not found or access denied" }, { status: 404 });
     }
 
+    // The worker also polls for PAUSED; this just stops a running job sooner.
+    try {
+      await ensureProducer();
+      await producer.send({
+        topic: "repo.ingest.control",
+        messages: [
+          {
+            key: id,
+            value: JSON.stringify({ repo: id, action: "pause", reason: "paused from dashboard" }),
+          },
+        ],
+      });
+    } catch (err) {
+      console.error("Pause control message failed:", err);
+    }
+
     return NextResponse.json({ message: "Repository paused successfully", repo: result[0] });
   } catch (error) {
     console.error("Pause API Error:", error);
@@ -1,10 +1,28 @@
 import { NextRequest, NextResponse } from "next/server";
+import { Kafka } from "kafkajs";
 import { getServerSession } from "next-auth";
 import { authOptions } from "@/lib/auth";
 import { db } from "@/lib/db";
-import { repositories } from "@/lib/schema";
+import { getRepoInstallationToken } from "@/lib/github";
+import { ingestionCheckpoints, repositories } from "@/lib/schema";
 import { eq } from "drizzle-orm";
 
+const kafka = new Kafka({
+  clientId: "sentinel-dashboard",
+  brokers: [process.env.KAFKA_BROKER || "localhost:9092"],
+  retry: { retries: 3 },
+});
+
+const producer = kafka.producer();
+let producerReady = false;
+
+async function ensureProducer() {
+  if (!producerReady) {
+    await producer.connect();
+    producerReady = true;
+  }
+}
+
 export async function PATCH(req: NextRequest) {
   const session = await getServerSession(authOptions);
   const userIdentifier =
@@ -51,6 +69,57 @@ export async function PATCH(req: NextRequest) {
     );
   }
 
+  // A job stopped mid-way left a checkpoint; queue it again so the worker
+  // picks up from there instead of leaving the repo half-ingested.
+  const checkpoint = await db
+    .select({ repo: ingestionCheckpoints.repo })
+    .from(ingestionCheckpoints)
+    .where(eq(ingestionCheckpoints.repo, id))
+    .limit(1);
+
+  if (checkpoint.length) {
+    try {
+      const accessToken = await getRepoInstallationToken(
+        Number(repo[0].installationId)
+      );
+
+      await ensureProducer();
+
+      await db
+        .update(repositories)
+        .set({
+          status: "QUEUED",
+          updatedAt: new Date(),
+        })
+        .where(eq(repositories.id, id));
+
+      await producer.send({
+        topic: "repo.analysis.request",
+        messages: [
+          {
+            key: id,
+            value: JSON.stringify({
+              type: "connection",
+              repo: id,
+              access_token: accessToken,
+            }),
+          },
+        ],
+      });
+    } catch (err) {
+      console.log(err);
+      return NextResponse.json(
+        { error: "Ingestion Service Unavailable" },
+        { status: 503 }
+      );
+    }
+
+    return NextResponse.json({
+      success: true,
+      status: "QUEUED",
+    });
+  }
+
   await db
     .update(repositories)
     .set({
@@ -1,4 +1,4 @@
-import { pgTable, text, timestamp, pgEnum, varchar , integer , jsonb, index, real, serial} from "drizzle-orm/pg-core";
+import { pgTable, text, timestamp, pgEnum, varchar , integer , jsonb, index, real, serial, uniqueIndex} from "drizzle-orm/pg-core";
 
 export const repoStatusEnum = pgEnum("repo_status", [
   "PAUSED",
@@ -29,6 +29,72 @@ export const repositories = pgTable("repositories", {
 });
 
 
+export const ingestionJobStages = pgTable(
+  "ingestion_job_stages",
+  {
+    id: serial("id").primaryKey(),
+    jobId: varchar("job_id", { length: 32 }).notNull(),
+    repo: text("repo").notNull(),
+    stage: varchar("stage", { length: 64 }).notNull(),
+    status: varchar("status", { length: 16 })
+      .$type<"RUNNING" | "OK" | "PARTIAL" | "FAILED" | "SKIPPED">()
+      .notNull(),
+    items: integer("items").notNull().default(0),
+    warnings: text("warnings").array(),
+    error: text("error"),
+    startedAt: timestamp("started_at").defaultNow().notNull(),
+    finishedAt: timestamp("finished_at"),
+    durationMs: integer("duration_ms"),
+  },
+  (table) => ({
+    jobIdx: index("ingestion_job_stages_job_idx").on(table.jobId),
+    repoStartedIdx: index("ingestion_job_stages_repo_started_idx").on(
+      table.repo,
+      table.startedAt
+    ),
+  })
+);
+
+export const ingestionCheckpoints = pgTable("ingestion_checkpoints", {
+  repo: text("repo").primaryKey(),
+  jobId: varchar("job_id", { length: 32 }).notNull(),
+  reason: text("reason"),
+  state: jsonb("state").notNull(),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+});
+
+export const repoSyncSchedule = pgTable("repo_sync_schedule", {
+  repo: text("repo").primaryKey(),
+  nextSyncAt: timestamp("next_sync_at").notNull(),
+  failures: integer("failures").notNull().default(0),
+  lastEnqueuedAt: timestamp("last_enqueued_at"),
+});
+
+export const ingestionBackfills = pgTable("ingestion_backfills", {
+  repo: text("repo").primaryKey(),
+  jobId: varchar("job_id", { length: 32 }).notNull(),
+  since: timestamp("since").notNull(),
+  cursor: timestamp("cursor").notNull(),
+  chunkDays: integer("chunk_days").notNull(),
+  chunks: integer("chunks").notNull().default(0),
+  status: varchar("status", { length: 16 }).notNull(),
+  error: text("error"),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+  updatedAt: timestamp("updated_at").defaultNow().notNull(),
+});
+
+export const orgIngestions = pgTable("org_ingestions", {
+  jobId: varchar("job_id", { length: 32 }).primaryKey(),
+  org: text("org").notNull(),
+  installationId: text("installation_id").notNull(),
+  status: varchar("status", { length: 16 }).notNull(),
+  filter: jsonb("filter"),
+  summary: jsonb("summary"),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+  updatedAt: timestamp("updated_at").defaultNow().notNull(),
+  finishedAt: timestamp("finished_at"),
+});
+
 export const usersTable = pgTable("users", {
   id: varchar({ length: 36 }).primaryKey(),
   name: varchar({ length: 255 }).notNull(),
@@ -55,6 +121,8 @@ export const repoFileEvents = pgTable(
         | "reverted_pr"
         | "rejected_pr"
         | "architecture"
+        | "security_alert"
+        | "rolled_back_release"
         | "sentinel_response"
       >()
       .notNull(),
@@ -86,6 +154,58 @@ export const repoFileEvents = pgTable(
 );
 
 
+export const fileCoChanges = pgTable(
+  "file_co_changes",
+  {
+    id: serial("id").primaryKey(),
+    repo: varchar("repo", { length: 255 }).notNull(),
+    fileA: text("file_a").notNull(),
+    fileB: text("file_b").notNull(),
+    coChanges: integer("co_changes").notNull(),
+    changesA: integer("changes_a").notNull(),
+    changesB: integer("changes_b").notNull(),
+    support: real("support").notNull(),
+    confidenceAB: real("confidence_ab").notNull(),
+    confidenceBA: real("confidence_ba").notNull(),
+    lift: real("lift").notNull(),
+    updatedAt: timestamp("updated_at").defaultNow().notNull(),
+  },
+  (table) => ({
+    repoPairIdx: uniqueIndex("file_co_changes_repo_pair_idx").on(
+      table.repo,
+      table.fileA,
+      table.fileB
+    ),
+    repoFileAIdx: index("file_co_changes_repo_file_a_idx").on(
+      table.repo,
+      table.fileA
+    ),
+    repoFileBIdx: index("file_co_changes_repo_file_b_idx").on(
+      table.repo,
+      table.fileB
+    ),
+  })
+);
+
+
+export const keywordDocFreqs = pgTable(
+  "keyword_doc_freqs",
+  {
+    id: serial("id").primaryKey(),
+    repo: varchar("repo", { length: 255 }).notNull(),
+    source: varchar("source", { length: 32 }).notNull(),
+    counts: jsonb("counts").notNull(),
+    updatedAt: timestamp("updated_at").defaultNow().notNull(),
+  },
+  (table) => ({
+    repoSourceIdx: uniqueIndex("keyword_doc_freqs_repo_source_idx").on(
+      table.repo,
+      table.source
+    ),
+  })
+);
+
+
 export const tokensTable = pgTable(
   "tokens",
   {
@@ -6,17 +6,20 @@ import { processIssues, log as Vectorlog } from "./processors/issues";
 import { processWorkflowCrash } from "./processors/workflow-crash";
 import { processRejectedPrs } from "./processors/rejected-pr";
 import { processRevertedPrs } from "./processors/reverted-pr";
+import { processSecurityAlerts } from "./processors/security-alert";
+import { processRolledBackPrs } from "./processors/rolled-back-release";
 
-import { initDB, updateStatus, markFailed } from "./lib/db/db";
+import { initDB, updateStatus, markFailed, startStage, finishStage } from "./lib/db/db";
 import { FileRiskEvent, recordFileEventsBatch } from "./lib/db/record_file_event";
 
 const TOPIC = "repo.analysis.ai";
 
-async function safeRun(name: string, fn: () => Promise<void>) {
+async function safeRun(name: string, fn: () => Promise<void>, warnings?: string[]) {
   try {
     await fn();
   } catch (error) {
     Vectorlog("error", `Task [${name}] failed: ${error}`);
+    warnings?.push(`${name}: ${error}`);
   }
 }
 
@@ -37,52 +40,82 @@ async function main() {
       
       const offset = message.offset;
       let repo : string = "";
+      let jobId : string = "";
+      let stageId : number | null = null;
+      let backfill = false;
 
       try {
         const payload = JSON.parse(message.value.toString());
 
         repo = payload.repo || payload.repository || "unknown"; 
         
+        jobId = payload.job_id || "";
+        // Backfill slices add history to a repo that is already READY, so
+        // they leave its status alone.
+        backfill = !!payload.backfill;
+
         Vectorlog("Job", `🚀 STARTING JOB | repo=${repo} | offset=${offset}`);
         const startTime = Date.now();
 
-        await updateStatus(repo, "ANALYZING");
+        if (!backfill) await updateStatus(repo, "ANALYZING");
+        if (jobId) stageId = await startStage(jobId, repo, "analyze");
 
         const issues = payload.bug?.Issues || payload.bug?.issues || [];
         const files = payload.rule?.Files || payload.rule?.files || [];
         const crashes = payload.workflow_crash?.Crash || [];
         const rejectedPrs = payload.rejected_prs || [];
         const revertedPrs = payload.reverted_prs || [];
+        const securityAlerts = payload.security?.Alerts || [];
+        const rolledBackPrs = (payload.merged_prs || []).filter(
+          (m: any) => m.pr?.release_rolled_back || m.pr?.deploy_rolled_back
+        );
 
         
         const eventBuffer: FileRiskEvent[] = [];
+        const warnings: string[] = [];
         const tasks = [
           safeRun("Issues", async () => {
             if (issues.length) await processIssues(repo, issues);
-          }),
+          }, warnings),
 
           safeRun("Architecture", async () => {
             if (files.length) await processArchitecture(repo, files);
-          }),
+          }, warnings),
 
           safeRun("Workflow", async () => {
             if (crashes.length) {
                await processWorkflowCrash(message, eventBuffer); 
             }
-          }),
+          }, warnings),
 
           safeRun("RejectedPRs", async () => {
             if (rejectedPrs.length) await processRejectedPrs(repo, rejectedPrs , eventBuffer);
-          }),
+          }, warnings),
 
           safeRun("RevertedPRs", async () => {
             if (revertedPrs.length) await processRevertedPrs(repo, revertedPrs, eventBuffer);
-          })
+          }, warnings),
+
+          safeRun("SecurityAlerts", async () => {
+            if (securityAlerts.length) await processSecurityAlerts(repo, securityAlerts, eventBuffer);
+          }, warnings),
+
+          safeRun("RolledBackReleases", async () => {
+            if (rolledBackPrs.length) await processRolledBackPrs(repo, rolledBackPrs, eventBuffer);
+          }, warnings)
         ];
 
         await Promise.allSettled(tasks);
+        await finishStage(stageId, eventBuffer.length, warnings);
+        stageId = null;
+
+        if (!backfill) await updateStatus(repo, "INDEXING");
+        if (jobId) stageId = await startStage(jobId, repo, "index");
         await recordFileEventsBatch(eventBuffer);
-        await updateStatus(repo, "READY");
+        await finishStage(stageId, eventBuffer.length, []);
+        stageId = null;
+
+        if (!backfill) await updateStatus(repo, "READY");
 
         const duration = ((Date.now() - startTime) / 1000).toFixed(2);
         Vectorlog("Job", `✅ FINISHED JOB | repo=${repo} | time=${duration}s`);
@@ -90,7 +123,8 @@ async function main() {
       } catch (error: any) {
         Vectorlog("Critical", `🔥 MESSAGE FAILED | repo=${repo} | error=${error.message}`);
         
-        if (repo !== "unknown") {
+        await finishStage(stageId, 0, [], error.message || "Unknown worker error");
+        if (repo !== "unknown" && !backfill) {
             await markFailed(repo, error.message || "Unknown worker error");
         }
       } finally {
@@ -62,6 +62,48 @@ export async function markFailed(repoId: string, errMsg: string) {
   }
 }
 
+// Job stages share ingestion_job_stages with the ingestion worker, keyed by
+// the job_id it puts on the envelope.
+export async function startStage(
+  jobId: string,
+  repo: string,
+  stage: string
+): Promise<number | null> {
+  try {
+    const res = await pool.query(
+      `INSERT INTO ingestion_job_stages (job_id, repo, stage, status, started_at)
+       VALUES ($1, $2, $3, 'RUNNING', NOW())
+       RETURNING id`,
+      [jobId, repo, stage]
+    );
+    return res.rows[0]?.id ?? null;
+  } catch (err) {
+    console.error(`❌ Failed to start stage ${stage} for ${repo}:`, err);
+    return null;
+  }
+}
+
+export async function finishStage(
+  id: number | null,
+  items: number,
+  warnings: string[],
+  error?: string
+) {
+  if (id === null) return;
+  try {
+    await pool.query(
+      `UPDATE ingestion_job_stages
+       SET status = $1, items = $2, warnings = $3, error = $4,
+           finished_at = NOW(),
+           duration_ms = (EXTRACT(EPOCH FROM (NOW() - started_at)) * 1000)::int
+       WHERE id = $5`,
+      [error ? "FAILED" : warnings.length ? "PARTIAL" : "OK", items, warnings, error ?? null, id]
+    );
+  } catch (err) {
+    console.error(`❌ Failed to finish stage ${id}:`, err);
+  }
+}
+
 process.on("SIGTERM", async () => {
   await pool?.end();
 });
\ No newline at end of file
@@ -10,7 +10,9 @@ export type FileRiskEvent = {
     | "workflow_crash"
     | "reverted_pr"
     | "rejected_pr"
-    | "architecture";
+    | "architecture"
+    | "security_alert"
+    | "rolled_back_release";
 
   event_source_id?: string;
 
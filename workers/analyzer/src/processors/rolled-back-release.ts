@@ -0,0 +1,97 @@
+import { upsertVectorsBatch } from "../vector/chroma";
+import { FileRiskEvent } from "@/lib/db/record_file_event";
+
+type RolledBackPR = {
+  repo: string;
+  pr: {
+    number: number;
+    title: string;
+    html_url: string;
+    merged_at?: string;
+    files?: string[];
+    release?: string;
+    release_rolled_back?: boolean;
+    deployed_at?: string;
+    deploy_environment?: string;
+    deploy_rolled_back?: boolean;
+    merge_to_deploy_hours?: number;
+  };
+};
+
+function log(tag: string, msg: string) {
+  const time = new Date().toISOString().replace(/T/, " ").replace(/\..+/, "");
+  console.log(`${time} [${tag}] ${msg}`);
+}
+
+// A merged PR that shipped in a release or deployment which was then rolled
+// back. This comes from deployment history rather than title matching, so it
+// is weighted above a plain revert and needs no model call.
+export async function processRolledBackPrs(
+  repo: string,
+  prs: RolledBackPR[],
+  eventBuffer: FileRiskEvent[]
+) {
+  log("rollback", `processing rolled back PRs | repo=${repo} count=${prs.length}`);
+
+  const vectors: { id: string; text: string; metadata: any }[] = [];
+  let events = 0;
+
+  for (const { pr } of prs) {
+    const files = pr.files ?? [];
+    if (files.length === 0) continue;
+
+    const shipped = [
+      pr.release ? `release ${pr.release}` : "",
+      pr.deploy_environment ? `${pr.deploy_environment} deployment` : "",
+    ]
+      .filter(Boolean)
+      .join(" and ");
+
+    const summary = `PR #${pr.number} "${pr.title}" shipped in ${shipped || "a release"} that was rolled back`;
+
+    for (const file of files) {
+      eventBuffer.push({
+        repo,
+        file_path: file,
+        affected_files: files.filter((f) => f !== file),
+        event_type: "rolled_back_release",
+        event_source_id: `pr-${pr.number}`,
+
+        severity_score: pr.deploy_rolled_back ? 0.9 : 0.8,
+        severity_label: "high",
+
+        risk_category: "rollback",
+        keywords: [pr.release, pr.deploy_environment].filter(
+          (k): k is string => !!k
+        ),
+        summary,
+        raw_payload: JSON.stringify(pr),
+        created_at: pr.deployed_at ?? pr.merged_at ?? new Date().toISOString(),
+      });
+      events++;
+    }
+
+    vectors.push({
+      id: `${repo}-ROLLBACK-${pr.number}`,
+      text: `
+ROLLBACK WARNING: ${summary}
+REPO: ${repo}
+${pr.merge_to_deploy_hours ? `MERGE TO DEPLOY: ${pr.merge_to_deploy_hours.toFixed(1)}h` : ""}
+FILES: ${files.join(", ")}
+      `.trim(),
+      metadata: {
+        repo,
+        type: "rolled_back_release",
+        pr: pr.number,
+        release: pr.release ?? "",
+        environment: pr.deploy_environment ?? "",
+      },
+    });
+  }
+
+  if (vectors.length > 0) {
+    await upsertVectorsBatch(repo, vectors);
+  }
+
+  log("rollback", `completed | repo=${repo} events=${events}`);
+}
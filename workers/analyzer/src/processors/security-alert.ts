@@ -0,0 +1,109 @@
+import { upsertVectorsBatch } from "../vector/chroma";
+import { FileRiskEvent } from "@/lib/db/record_file_event";
+
+type SecurityAlert = {
+  source: "dependabot" | "code_scanning" | "secret_scanning";
+  number: number;
+  state: string;
+  severity: "low" | "medium" | "high" | "critical";
+  rule: string;
+  summary: string;
+  html_url: string;
+  file?: string;
+  start_line?: number;
+  package?: string;
+  ecosystem?: string;
+  vulnerable_range?: string;
+  patched_version?: string;
+  identifiers?: string[];
+  tool?: string;
+  locations?: string[];
+  created_at: string;
+  resolved_at?: string;
+  resolution?: string;
+};
+
+const SEVERITY_SCORE: Record<SecurityAlert["severity"], number> = {
+  critical: 1.0,
+  high: 0.8,
+  medium: 0.5,
+  low: 0.2,
+};
+
+function log(tag: string, msg: string) {
+  const time = new Date().toISOString().replace(/T/, " ").replace(/\..+/, "");
+  console.log(`${time} [${tag}] ${msg}`);
+}
+
+// Alerts are already structured, so unlike crashes and PRs they go straight
+// to file events without a model call. Fixed or dismissed alerts still count
+// as history, at half weight.
+export async function processSecurityAlerts(
+  repo: string,
+  alerts: SecurityAlert[],
+  eventBuffer: FileRiskEvent[]
+) {
+  log("security", `processing alerts | repo=${repo} count=${alerts.length}`);
+
+  const vectors: { id: string; text: string; metadata: any }[] = [];
+
+  for (const alert of alerts) {
+    const open = alert.state === "open";
+    const base = SEVERITY_SCORE[alert.severity] ?? 0.2;
+
+    const keywords = [
+      alert.rule,
+      alert.package,
+      alert.tool,
+      ...(alert.identifiers ?? []),
+    ].filter((k): k is string => !!k);
+
+    const where = alert.file
+      ? `${alert.file}${alert.start_line ? `:${alert.start_line}` : ""}`
+      : "unknown";
+
+    eventBuffer.push({
+      repo,
+      file_path: alert.file || "unknown",
+      affected_files: (alert.locations ?? []).filter((l) => l !== alert.file),
+      event_type: "security_alert",
+      event_source_id: `${alert.source}-${alert.number}`,
+
+      severity_score: open ? base : base / 2,
+      severity_label: alert.severity,
+
+      risk_category: alert.source,
+      keywords,
+      summary: `[${alert.state}] ${alert.summary}`,
+      raw_payload: JSON.stringify(alert),
+      created_at: alert.created_at,
+    });
+
+    vectors.push({
+      id: `${repo}-SECURITY-${alert.source}-${alert.number}`,
+      text: `
+SECURITY ALERT (${alert.source}): ${alert.summary}
+REPO: ${repo}
+SEVERITY: ${alert.severity}
+STATE: ${alert.state}${alert.resolution ? ` (${alert.resolution})` : ""}
+LOCATION: ${where}
+${alert.package ? `PACKAGE: ${alert.package} ${alert.vulnerable_range ?? ""} -> ${alert.patched_version ?? "no fix"}` : ""}
+RULE: ${alert.rule}
+      `.trim(),
+      metadata: {
+        repo,
+        type: "security_alert",
+        source: alert.source,
+        severity: alert.severity,
+        state: alert.state,
+        file: alert.file ?? "unknown",
+      },
+    });
+  }
+
+  if (vectors.length > 0) {
+    await upsertVectorsBatch(repo, vectors);
+  }
+
+  log("security", `completed | repo=${repo} events=${alerts.length}`);
+}
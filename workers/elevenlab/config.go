@@ -0,0 +1,35 @@
+package main
+
+import (
+	shared "codrel-sentinel/workers/shared/config"
+)
+
+// Config is what the voice worker reads at startup; see the shared config
+// package for the tag format.
+type Config struct {
+	Kafka     shared.Kafka    `yaml:"kafka"`
+	ElevenLab ElevenLabConfig `yaml:"elevenlab"`
+}
+
+type ElevenLabConfig struct {
+	GroupID string `yaml:"consumer_group" env:"ELEVENLAB_CONSUMER_GROUP" default:"elevenlab-worker" required:"true"`
+	Topic   string `yaml:"topic" env:"ELEVENLAB_TOPIC" default:"codrel.index.jobs" required:"true"`
+
+	ElevenKey   string `yaml:"elevenlabs_api_key" env:"ELEVENLABS_API_KEY" required:"true" secret:"true"`
+	ElevenVoice string `yaml:"elevenlabs_voice_id" env:"ELEVENLABS_VOICE_ID" required:"true"`
+
+	TwilioSID   string `yaml:"twilio_sid" env:"TWILIO_SID,TWILIO_ACCOUNT_SID" required:"true"`
+	TwilioToken string `yaml:"twilio_token" env:"TWILIO_TOKEN,TWILIO_AUTH_TOKEN" required:"true" secret:"true"`
+	TwilioFrom  string `yaml:"twilio_from" env:"TWILIO_FROM" required:"true"`
+	Number      string `yaml:"alert_phone_number" env:"ALERT_PHONE_NUMBER" required:"true"`
+
+	// UploadURL receives the generated audio and answers with a public URL
+	// Twilio can play.
+	UploadURL string `yaml:"upload_url" env:"ELEVENLAB_UPLOAD_URL" default:"https://3000.vinitngr.xyz/api/call-alert/upload-audio" required:"true"`
+}
+
+func LoadConfig() (Config, error) {
+	var cfg Config
+	err := shared.Load(&cfg)
+	return cfg, err
+}
@@ -3,6 +3,11 @@ module codrel-sentinel/workers/elevenlab
 go 1.24.0
 
 require (
+	codrel-sentinel/workers/shared v0.0.0
 	github.com/confluentinc/confluent-kafka-go v1.9.2
 	github.com/joho/godotenv v1.5.1
 )
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace codrel-sentinel/workers/shared => ../shared
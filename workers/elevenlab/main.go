@@ -10,16 +10,22 @@ import (
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/joho/godotenv"
+
+	shared "codrel-sentinel/workers/shared/config"
 )
 
 func main() {
 	_ = godotenv.Load()
 
-	cfg := LoadConfig()
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("voice worker config:\n%s", shared.Dump(&cfg))
 
 	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": cfg.Brokers,
-		"group.id":          cfg.GroupID,
+		"bootstrap.servers": cfg.Kafka.Brokers,
+		"group.id":          cfg.ElevenLab.GroupID,
 		"auto.offset.reset": "earliest",
 	})
 	if err != nil {
@@ -27,7 +33,7 @@ func main() {
 	}
 	defer consumer.Close()
 
-	if err := consumer.SubscribeTopics([]string{cfg.Topic}, nil); err != nil {
+	if err := consumer.SubscribeTopics([]string{cfg.ElevenLab.Topic}, nil); err != nil {
 		log.Fatal(err)
 	}
 
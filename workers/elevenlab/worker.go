@@ -16,40 +16,8 @@ import (
 	"syscall"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
-	"github.com/joho/godotenv"
 )
 
-type Config struct {
-	Brokers string
-	GroupID string
-	Topic   string
-
-	ElevenKey   string
-	ElevenVoice string
-
-	TwilioSID   string
-	TwilioToken string
-	TwilioFrom  string
-	Number      string
-}
-
-func LoadConfig() Config {
-	_ = godotenv.Load()
-
-	return Config{
-		Brokers:     os.Getenv("KAFKA_BROKERS"),
-		GroupID:     "elevenlab-worker",
-		Topic:       "codrel.index.jobs",
-		ElevenKey:   os.Getenv("ELEVENLABS_API_KEY"),
-		ElevenVoice: os.Getenv("ELEVENLABS_VOICE_ID"),
-		TwilioSID:   os.Getenv("TWILIO_SID"),
-		TwilioToken: os.Getenv("TWILIO_TOKEN"),
-		TwilioFrom:  os.Getenv("TWILIO_FROM"),
-
-		Number: os.Getenv("ALERT_PHONE_NUMBER"),
-	}
-}
-
 type Job struct {
 	EventID  string `json:"eventId"`
 	Message  string `json:"message"`
@@ -58,8 +26,8 @@ type Job struct {
 
 func StartWorker(cfg Config) error {
 	c, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": cfg.Brokers,
-		"group.id":          cfg.GroupID,
+		"bootstrap.servers": cfg.Kafka.Brokers,
+		"group.id":          cfg.ElevenLab.GroupID,
 		"auto.offset.reset": "earliest",
 		"log_level":         3,
 	})
@@ -68,7 +36,7 @@ func StartWorker(cfg Config) error {
 	}
 	defer c.Close()
 
-	if err := c.Subscribe(cfg.Topic, nil); err != nil {
+	if err := c.Subscribe(cfg.ElevenLab.Topic, nil); err != nil {
 		return err
 	}
 
@@ -127,12 +95,12 @@ func HandleJob(cfg Config, job Job) error {
 		return err
 	}
 
-	audioURL, err := UploadAudio(job.EventID, audio)
+	audioURL, err := UploadAudio(cfg, job.EventID, audio)
 	if err != nil {
 		return err
 	}
 
-	return CallTwilioRaw(cfg.Number, audioURL)
+	return CallTwilioRaw(cfg, cfg.ElevenLab.Number, audioURL)
 }
 
 func GenerateSpeech(cfg Config, text string) ([]byte, error) {
@@ -145,11 +113,11 @@ func GenerateSpeech(cfg Config, text string) ([]byte, error) {
 
 	req, _ := http.NewRequest(
 		"POST",
-		fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s", cfg.ElevenVoice),
+		fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s", cfg.ElevenLab.ElevenVoice),
 		bytes.NewReader(b),
 	)
 
-	req.Header.Set("xi-api-key", cfg.ElevenKey)
+	req.Header.Set("xi-api-key", cfg.ElevenLab.ElevenKey)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
@@ -166,7 +134,7 @@ func GenerateSpeech(cfg Config, text string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
-func UploadAudio(eventId string, audio []byte) (string, error) {
+func UploadAudio(cfg Config, eventId string, audio []byte) (string, error) {
 	payload := map[string]any{
 		"eventId":     eventId,
 		"audioBase64": base64.StdEncoding.EncodeToString(audio),
@@ -175,7 +143,7 @@ func UploadAudio(eventId string, audio []byte) (string, error) {
 	b, _ := json.Marshal(payload)
 
 	resp, err := http.Post(
-		"https://3000.vinitngr.xyz/api/call-alert/upload-audio",
+		cfg.ElevenLab.UploadURL,
 		"application/json",
 		bytes.NewReader(b),
 	)
@@ -192,10 +160,10 @@ func UploadAudio(eventId string, audio []byte) (string, error) {
 	return res.AudioURL, nil
 }
 
-func CallTwilioRaw(to, audioURL string) error {
-	sid := os.Getenv("TWILIO_SID")
-	token := os.Getenv("TWILIO_TOKEN")
-	from := os.Getenv("TWILIO_FROM")
+func CallTwilioRaw(cfg Config, to, audioURL string) error {
+	sid := cfg.ElevenLab.TwilioSID
+	token := cfg.ElevenLab.TwilioToken
+	from := cfg.ElevenLab.TwilioFrom
 
 	twiml := fmt.Sprintf(`<Response><Play>%s</Play></Response>`, audioURL)
 
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	shared "codrel-sentinel/workers/shared/config"
+)
+
+// Config is what the events worker reads at startup; see the shared config
+// package for the tag format.
+type Config struct {
+	Kafka  shared.Kafka `yaml:"kafka"`
+	Events EventsConfig `yaml:"events"`
+}
+
+type EventsConfig struct {
+	Topic string `yaml:"topic" env:"EVENTS_TOPIC" default:"codrel.risk.events" required:"true"`
+	// Mode is "mock" until the Kafka consumer lands; mock emits a canned
+	// event every MockTick.
+	Mode     string        `yaml:"mode" env:"EVENTS_MODE" default:"mock"`
+	MockTick time.Duration `yaml:"mock_tick" env:"EVENTS_MOCK_TICK" default:"8s"`
+}
+
+func (e *EventsConfig) Validate() error {
+	if e.Mode != "mock" {
+		return fmt.Errorf("events.mode: unsupported mode %q", e.Mode)
+	}
+	if e.MockTick <= 0 {
+		return fmt.Errorf("events.mock_tick must be positive")
+	}
+	return nil
+}
+
+func LoadConfig() (Config, error) {
+	var cfg Config
+	err := shared.Load(&cfg)
+	return cfg, err
+}
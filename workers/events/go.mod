@@ -1,3 +1,9 @@
 module codrel-sentinel/workers/events
 
 go 1.21
+
+require codrel-sentinel/workers/shared v0.0.0
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace codrel-sentinel/workers/shared => ../shared
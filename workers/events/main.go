@@ -7,6 +7,8 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	shared "codrel-sentinel/workers/shared/config"
 )
 
 type RiskAssessment struct {
@@ -31,7 +33,14 @@ type DatadogMetric struct {
 
 func main() {
 	log.Println("[Events Worker] Starting...")
-	log.Println("[Events Worker] Consuming: codrel.risk.events")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("[Events Worker] Config:\n%s", shared.Dump(&cfg))
+
+	log.Printf("[Events Worker] Consuming: %s", cfg.Events.Topic)
 	log.Println("[Events Worker] Producing: Datadog metrics")
 	log.Println("[Events Worker] Mode: Mock")
 
@@ -39,7 +48,7 @@ func main() {
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		ticker := time.NewTicker(8 * time.Second)
+		ticker := time.NewTicker(cfg.Events.MockTick)
 		defer ticker.Stop()
 
 		for range ticker.C {
@@ -62,6 +62,39 @@ func (a *GitHubApp) GetInstallationToken(owner, repo string) (string, error) {
 		return "", err
 	}
 
+	return createInstallationToken(jwtToken, installationID)
+}
+
+// GetOrgInstallationToken returns a token for the installation on an org or
+// user account, along with the installation id.
+func (a *GitHubApp) GetOrgInstallationToken(org string) (string, string, error) {
+	jwtToken, err := a.generateJWT()
+	if err != nil {
+		return "", "", err
+	}
+
+	installationID, err := getAccountInstallationID(jwtToken, "/orgs/"+org+"/installation")
+	if err != nil {
+		installationID, err = getAccountInstallationID(jwtToken, "/users/"+org+"/installation")
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	token, err := createInstallationToken(jwtToken, installationID)
+	return token, installationID, err
+}
+
+// GetInstallationTokenByID returns a token for a known installation.
+func (a *GitHubApp) GetInstallationTokenByID(installationID string) (string, error) {
+	jwtToken, err := a.generateJWT()
+	if err != nil {
+		return "", err
+	}
+	return createInstallationToken(jwtToken, installationID)
+}
+
+func createInstallationToken(jwtToken, installationID string) (string, error) {
 	req, _ := http.NewRequest(
 		"POST",
 		githubAPI+"/app/installations/"+installationID+"/access_tokens",
@@ -89,9 +122,13 @@ func (a *GitHubApp) GetInstallationToken(owner, repo string) (string, error) {
 }
 
 func getInstallationID(jwtToken, owner, repo string) (string, error) {
+	return getAccountInstallationID(jwtToken, "/repos/"+owner+"/"+repo+"/installation")
+}
+
+func getAccountInstallationID(jwtToken, path string) (string, error) {
 	req, _ := http.NewRequest(
 		"GET",
-		githubAPI+"/repos/"+owner+"/"+repo+"/installation",
+		githubAPI+path,
 		nil,
 	)
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
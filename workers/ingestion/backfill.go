@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+	gogithub "github.com/google/go-github/v61/github"
+
+	"codrel-sentinel/workers/ingestion-worker/control"
+	"codrel-sentinel/workers/ingestion-worker/db"
+	"codrel-sentinel/workers/ingestion-worker/github"
+	"codrel-sentinel/workers/ingestion-worker/kafka"
+	"codrel-sentinel/workers/ingestion-worker/keywords"
+	"codrel-sentinel/workers/ingestion-worker/model"
+	"codrel-sentinel/workers/ingestion-worker/progress"
+)
+
+const (
+	defaultBackfillYears = 2
+	defaultChunkDays     = 30
+
+	// Installation tokens last an hour; a backfill takes a new one between
+	// slices well before then.
+	tokenRefreshAfter = 45 * time.Minute
+)
+
+// Backfill statuses, as stored in ingestion_backfills.
+const (
+	backfillQueued    = "QUEUED"
+	backfillRunning   = "RUNNING"
+	backfillPaused    = "PAUSED"
+	backfillCancelled = "CANCELLED"
+	backfillFailed    = "FAILED"
+	backfillDone      = "DONE"
+)
+
+// BackfillChunk marks an envelope as one slice of a backfill rather than a
+// full ingestion, so the analyzer leaves the repo status alone.
+type BackfillChunk struct {
+	Index int       `json:"index"`
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Last  bool      `json:"last"`
+}
+
+// runBackfill walks a repo's history from the start of the regular window
+// back to the requested date, one slice at a time, newest first. Each slice
+// is emitted as soon as it is fetched and the cursor is saved after it, so a
+// stopped backfill picks up at the first slice not yet emitted.
+func runBackfill(
+	ctx context.Context,
+	req *model.IngestRequest,
+	client *gogithub.Client,
+	owner string,
+	repo string,
+	producer *ckafka.Producer,
+) {
+	if status, err := db.GetStatus(req.Repo); err == nil && status == progress.StatusPaused {
+		log.Printf("%s is paused, skipping backfill", req.Repo)
+		return
+	}
+
+	b, err := planBackfill(req)
+	if err != nil {
+		log.Printf("backfill for %s: %v", req.Repo, err)
+		return
+	}
+	if !b.Cursor.After(b.Since) {
+		log.Printf("backfill for %s already reaches %s", req.Repo, b.Since.Format(time.DateOnly))
+		return
+	}
+
+	jobCtx, release := registry.Start(ctx, req.Repo)
+	defer release()
+	go registry.WatchStatus(jobCtx, req.Repo, statusPollInterval)
+
+	job := progress.NewJob(req.Repo, fatalStages)
+	b.JobID = job.ID
+	b.Status = backfillRunning
+	b.Error = ""
+	save := func() {
+		if err := db.SaveBackfill(b); err != nil {
+			log.Println("save backfill failed:", err)
+		}
+	}
+	save()
+
+	// A backfill starting from the top restarts its running keyword counts;
+	// a resumed or extended one adds to them.
+	stored := loadKeywordCounts(req.Repo)
+	total := stored[keywordsBackfill]
+	if b.Chunks == 0 {
+		total = keywords.Counts{}
+	}
+
+	log.Printf(
+		"backfill %s | job=%s since=%s cursor=%s chunk=%dd done=%d",
+		req.Repo,
+		job.ID,
+		b.Since.Format(time.DateOnly),
+		b.Cursor.Format(time.DateOnly),
+		b.ChunkDays,
+		b.Chunks,
+	)
+
+	tokenAt := time.Now()
+	for b.Cursor.After(b.Since) {
+		if githubApp != nil && time.Since(tokenAt) > tokenRefreshAfter {
+			if token, err := githubApp.GetInstallationToken(owner, repo); err != nil {
+				log.Printf("backfill for %s: refreshing installation token failed: %v", req.Repo, err)
+			} else {
+				req.AccessToken, client, tokenAt = token, github.NewClient(token), time.Now()
+			}
+		}
+
+		w := sliceWindow(jobCtx, client, owner, repo, b)
+
+		envelope, counts := backfillChunk(jobCtx, job, req, client, owner, repo, w, stored.past(keywordsSync), total)
+		if jobCtx.Err() != nil {
+			stopBackfill(b, req, producer, control.Cause(jobCtx))
+			return
+		}
+		if stage, err := job.Fatal(); err != nil {
+			// A rejected token is no fault of the backfill: leave it queued so
+			// the scheduler resumes it from this slice with a fresh token.
+			b.Status, b.Error = backfillFailed, fmt.Sprintf("%s: %v", stage, err)
+			if github.IsAuthError(err) {
+				b.Status = backfillQueued
+			}
+			save()
+			log.Printf("backfill for %s stopped at %s: %s | status=%s", req.Repo, w.Until.Format(time.DateOnly), b.Error, b.Status)
+			return
+		}
+
+		envelope.Backfill = &BackfillChunk{
+			Index: b.Chunks,
+			Since: w.Since,
+			Until: w.Until,
+			Last:  !w.Since.After(b.Since),
+		}
+
+		emitStage := job.Stage("emit")
+		payload, report, err := redactor.JSON(envelope)
+		if err == nil {
+			payload["redactions"] = report
+			err = emitEnvelope(producer, payload)
+		}
+		emitStage.Done(len(envelope.RevertedPRs)+len(envelope.RejectedPRs)+len(envelope.MergedPRs), err)
+		if err != nil {
+			b.Status, b.Error = backfillFailed, "emit: "+err.Error()
+			save()
+			log.Printf("backfill for %s failed to emit: %v", req.Repo, err)
+			return
+		}
+
+		b.Chunks++
+		b.Cursor = w.Since
+		save()
+
+		if counts.Docs > 0 {
+			total = mergeKeywordCounts(total, counts)
+			if err := saveKeywordCounts(req.Repo, keywordsBackfill, total); err != nil {
+				log.Printf("backfill for %s: save keyword counts failed: %v", req.Repo, err)
+			}
+		}
+	}
+
+	b.Status = backfillDone
+	save()
+	log.Printf("backfill for %s done | slices=%d", req.Repo, b.Chunks)
+}
+
+// sliceWindow picks the next slice below the cursor. A slice with more
+// closed PRs than one search should return is halved until it fits or is a
+// single day, so busy periods are read in full instead of cut off.
+func sliceWindow(ctx context.Context, client *gogithub.Client, owner, repo string, b *db.Backfill) github.Window {
+	span := time.Duration(b.ChunkDays) * 24 * time.Hour
+	for {
+		w := github.Window{Since: b.Cursor.Add(-span), Until: b.Cursor}
+		if w.Since.Before(b.Since) {
+			w.Since = b.Since
+		}
+		if span <= 24*time.Hour {
+			return w
+		}
+
+		if err := githubLimiter.Wait(ctx); err != nil {
+			return w
+		}
+		n, err := github.CountClosedPRs(ctx, client, owner, repo, w)
+		if err != nil {
+			log.Printf("backfill for %s: counting PRs failed: %v", b.Repo, err)
+			return w
+		}
+		if n <= github.MaxBackfillPRs {
+			return w
+		}
+		log.Printf("backfill for %s: %d closed PRs in %s..%s, halving the slice", b.Repo, n, w.Since.Format(time.DateOnly), w.Until.Format(time.DateOnly))
+		span /= 2
+	}
+}
+
+// planBackfill works out where a backfill starts. A request without since,
+// or with the same since, resumes an unfinished backfill from its cursor; a
+// finished one is only extended further back.
+func planBackfill(req *model.IngestRequest) (*db.Backfill, error) {
+	prev, err := db.LoadBackfill(req.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().AddDate(-defaultBackfillYears, 0, 0)
+	if req.Since != "" {
+		if since, err = parseSince(req.Since); err != nil {
+			return nil, fmt.Errorf("invalid since %q: %w", req.Since, err)
+		}
+	}
+
+	if prev != nil && prev.Status != backfillDone && (req.Since == "" || since.Equal(prev.Since)) {
+		if req.ChunkDays > 0 {
+			prev.ChunkDays = req.ChunkDays
+		}
+		return prev, nil
+	}
+
+	b := &db.Backfill{
+		Repo:      req.Repo,
+		Since:     since,
+		Cursor:    github.WindowStart(),
+		ChunkDays: req.ChunkDays,
+	}
+	if b.ChunkDays <= 0 {
+		b.ChunkDays = defaultChunkDays
+	}
+	if prev != nil && prev.Status == backfillDone && prev.Since.Before(b.Cursor) {
+		// Everything after the earlier backfill's start is already in.
+		b.Cursor = prev.Since
+		b.Chunks = prev.Chunks
+	}
+	return b, nil
+}
+
+func parseSince(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// backfillChunk fetches one slice. Only what can be sliced by date is read;
+// architecture, security, history and releases describe the repo as it is
+// now and come with the regular sync.
+func backfillChunk(
+	ctx context.Context,
+	job *progress.Job,
+	req *model.IngestRequest,
+	client *gogithub.Client,
+	owner string,
+	repo string,
+	w github.Window,
+	past []keywords.Counts,
+	done keywords.Counts,
+) (AnalysisEnvelope, keywords.Counts) {
+	envelope := AnalysisEnvelope{
+		Repo:  req.Repo,
+		JobID: job.ID,
+	}
+
+	var stages sync.WaitGroup
+	stages.Add(2)
+
+	go func() {
+		defer stages.Done()
+		stage := job.Stage("crashes")
+		stage.CoverRange(w.Since, w.Until, github.MaxWorkflowFailures)
+		crashes, err := github.FetchWorkflowFailuresIn(ctx, client, owner, repo, w)
+		stage.Done(len(crashes), err)
+		if err == nil {
+			envelope.WorkflowCrash = &model.WorkflowCrashPayload{Crash: crashes}
+		}
+	}()
+
+	go func() {
+		defer stages.Done()
+		stage := job.Stage("bugs")
+		stage.CoverRange(w.Since, w.Until, github.MaxClosedIssues)
+		issues, err := github.FetchClosedIssuesRawIn(ctx, req.AccessToken, owner, repo, w)
+		stage.Done(len(issues), err)
+		if err == nil {
+			envelope.Bug = &model.BugPayload{Issues: issues}
+		}
+	}()
+
+	prStage := job.Stage("pr_buckets")
+	prStage.CoverRange(w.Since, w.Until, 0)
+	buckets := &github.PRBuckets{}
+	err := githubLimiter.Wait(ctx)
+	if err == nil {
+		var found *github.PRBuckets
+		found, err = github.FetchClosedPRBucketsIn(ctx, client, owner, repo, w)
+		if err == nil {
+			buckets = found
+		}
+	}
+	if err != nil {
+		log.Println("github fetch failed:", err)
+	}
+	if buckets.Unlisted > 0 {
+		prStage.Warn("%d closed PRs past the search limit were not read", buckets.Unlisted)
+	}
+	prStage.Done(len(buckets.Reverted)+len(buckets.Rejected)+len(buckets.Merged), err)
+
+	stages.Wait()
+	if ctx.Err() != nil {
+		return envelope, keywords.Counts{}
+	}
+
+	annotateStage := job.Stage("annotate")
+	var (
+		issues  []github.Issue
+		crashes []github.WorkflowCrash
+	)
+	if envelope.Bug != nil {
+		issues = envelope.Bug.Issues
+	}
+	if envelope.WorkflowCrash != nil {
+		crashes = envelope.WorkflowCrash.Crash
+	}
+	counts := github.AnnotateKeywords(append(past, done), issues, crashes, buckets.Reverted, buckets.Rejected, buckets.Merged)
+	envelope.SymbolHistory = github.BuildSymbolHistory(buckets.Reverted)
+	annotateStage.Done(len(envelope.SymbolHistory), nil)
+
+	// The job spans every slice; drop the previous slice's emit so Stages
+	// only describes this one.
+	envelope.Stages = job.Results()
+	delete(envelope.Stages, "emit")
+
+	for _, pr := range buckets.Reverted {
+		envelope.RevertedPRs = append(envelope.RevertedPRs, revertedPayload(req.Repo, pr))
+	}
+	for _, pr := range buckets.Rejected {
+		envelope.RejectedPRs = append(envelope.RejectedPRs, model.RejectedPRPayload{Repo: req.Repo, PR: pr})
+	}
+	for _, pr := range buckets.Merged {
+		envelope.MergedPRs = append(envelope.MergedPRs, model.MergedPRPayload{Repo: req.Repo, PR: pr})
+	}
+	return envelope, counts
+}
+
+// stopBackfill records why a backfill stopped. The cursor already points at
+// the slice that was cut short, so that slice runs again on resume. On
+// shutdown a token-less request is queued straight away so another worker
+// resumes it; if that fails the scheduler picks it up once it looks stalled.
+func stopBackfill(b *db.Backfill, req *model.IngestRequest, producer *ckafka.Producer, cause error) {
+	switch {
+	case errors.Is(cause, control.ErrShutdown):
+		b.Status = backfillRunning
+		if err := kafka.PublishRequest(producer, cfg.Ingestion.RequestTopic, model.IngestRequest{Repo: req.Repo, Type: "backfill"}); err != nil {
+			log.Printf("requeue backfill for %s failed: %v", req.Repo, err)
+		} else {
+			b.Status = backfillQueued
+		}
+	case errors.Is(cause, control.ErrPaused):
+		b.Status = backfillPaused
+	default:
+		b.Status, b.Error = backfillCancelled, cause.Error()
+	}
+
+	if err := db.SaveBackfill(b); err != nil {
+		log.Println("save backfill failed:", err)
+	}
+	log.Printf("backfill for %s stopped (%v) | status=%s cursor=%s", req.Repo, cause, b.Status, b.Cursor.Format(time.DateOnly))
+}
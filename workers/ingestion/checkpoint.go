@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"codrel-sentinel/workers/ingestion-worker/db"
+	"codrel-sentinel/workers/ingestion-worker/github"
+	"codrel-sentinel/workers/ingestion-worker/model"
+	"codrel-sentinel/workers/ingestion-worker/progress"
+)
+
+// checkpointStages are the stages whose output a stopped job keeps. The
+// import graph has no serialised form and is cheap to rebuild; annotate and
+// emit always run again.
+var checkpointStages = map[string]bool{
+	"crashes":        true,
+	"bugs":           true,
+	"architecture":   true,
+	"security":       true,
+	"history":        true,
+	"pr_buckets":     true,
+	"direct_reverts": true,
+	"releases":       true,
+}
+
+// checkpointMaxAge is how long a checkpoint stays usable. Stage windows are
+// relative to the time they ran, so older output would be restored as if
+// it were fresh; past this age every stage runs again.
+const checkpointMaxAge = 24 * time.Hour
+
+// checkpoint is what a paused or cancelled job leaves behind: the result of
+// every stage that finished and that stage's output.
+type checkpoint struct {
+	JobID   string                     `json:"job_id"`
+	Reason  string                     `json:"reason"`
+	SavedAt time.Time                  `json:"saved_at"`
+	Stages  map[string]progress.Result `json:"stages"`
+
+	WorkflowCrash *model.WorkflowCrashPayload `json:"workflow_crash,omitempty"`
+	Bug           *model.BugPayload           `json:"bug,omitempty"`
+	Rule          *model.ArchPayload          `json:"rule,omitempty"`
+	Security      *model.SecurityPayload      `json:"security,omitempty"`
+	History       []github.HistoryCommit      `json:"history,omitempty"`
+	PRBuckets     *github.PRBuckets           `json:"pr_buckets,omitempty"`
+	DirectReverts []github.MinimalPR          `json:"direct_reverts,omitempty"`
+	Releases      *github.ReleaseHistory      `json:"releases,omitempty"`
+}
+
+// loadCheckpoint never returns nil; without a stored checkpoint nothing is
+// restored.
+func loadCheckpoint(repo string) *checkpoint {
+	cp := &checkpoint{Stages: map[string]progress.Result{}}
+
+	state, err := db.LoadCheckpoint(repo)
+	if err != nil {
+		log.Println("load checkpoint failed:", err)
+		return cp
+	}
+	if state == nil {
+		return cp
+	}
+	if err := json.Unmarshal(state, cp); err != nil {
+		log.Println("decode checkpoint failed:", err)
+		return &checkpoint{Stages: map[string]progress.Result{}}
+	}
+	if age := time.Since(cp.SavedAt); age > checkpointMaxAge {
+		log.Printf("ignoring checkpoint of job %s for %s, saved %s ago", cp.JobID, repo, age.Round(time.Minute))
+		return &checkpoint{Stages: map[string]progress.Result{}}
+	}
+
+	log.Printf("resuming %s from checkpoint of job %s (%s), %d stage(s) done", repo, cp.JobID, cp.Reason, len(cp.Stages))
+	return cp
+}
+
+// saveCheckpoint stores cp with only the stages that finished usefully.
+// Failed stages, including those cut off by the cancellation, run again.
+func saveCheckpoint(repo string, cp *checkpoint, results map[string]progress.Result, reason error) {
+	cp.Reason = reason.Error()
+	cp.SavedAt = time.Now()
+	cp.Stages = map[string]progress.Result{}
+	for name, res := range results {
+		if checkpointStages[name] && res.Status != progress.ResultFailed {
+			cp.Stages[name] = res
+		}
+	}
+
+	state, err := json.Marshal(cp)
+	if err != nil {
+		log.Println("encode checkpoint failed:", err)
+		return
+	}
+	if err := db.SaveCheckpoint(repo, cp.JobID, cp.Reason, state); err != nil {
+		log.Println("save checkpoint failed:", err)
+		return
+	}
+	log.Printf("saved checkpoint for %s | stages=%d reason=%s", repo, len(cp.Stages), cp.Reason)
+}
+
+// restore copies a stage's saved output into dst and reports whether the
+// stage can be skipped.
+func restore[T any](cp *checkpoint, job *progress.Job, stage string, saved T, dst *T) bool {
+	res, ok := cp.Stages[stage]
+	if !ok {
+		return false
+	}
+	*dst = saved
+	job.Restore(stage, res)
+	return true
+}
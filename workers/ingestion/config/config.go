@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"time"
+
+	shared "codrel-sentinel/workers/shared/config"
+)
+
+// Config is everything the ingestion worker reads at startup. See the shared
+// config package for how tags map to YAML keys and environment variables.
+type Config struct {
+	Kafka     shared.Kafka    `yaml:"kafka"`
+	Database  shared.Database `yaml:"database"`
+	GitHubApp GitHubApp       `yaml:"github_app"`
+	Ingestion Ingestion       `yaml:"ingestion"`
+}
+
+// GitHubApp lets the worker mint installation tokens for requests that come
+// without one, such as scheduled syncs. Set both fields or neither.
+type GitHubApp struct {
+	AppID          string `yaml:"app_id" env:"GITHUB_APP_ID"`
+	PrivateKeyPath string `yaml:"private_key_path" env:"GITHUB_PRIVATE_KEY_PATH"`
+}
+
+func (a GitHubApp) Enabled() bool {
+	return a.AppID != "" && a.PrivateKeyPath != ""
+}
+
+func (a *GitHubApp) Validate() error {
+	if (a.AppID == "") != (a.PrivateKeyPath == "") {
+		return errors.New("github_app: app_id and private_key_path go together")
+	}
+	return nil
+}
+
+type Ingestion struct {
+	RequestTopic  string `yaml:"request_topic" env:"INGEST_REQUEST_TOPIC" default:"repo.analysis.request" required:"true"`
+	AnalysisTopic string `yaml:"analysis_topic" env:"INGEST_ANALYSIS_TOPIC" default:"repo.analysis.ai" required:"true"`
+	ControlTopic  string `yaml:"control_topic" env:"INGEST_CONTROL_TOPIC" default:"repo.ingest.control" required:"true"`
+	ConsumerGroup string `yaml:"consumer_group" env:"INGEST_CONSUMER_GROUP" default:"go-ingest-worker" required:"true"`
+
+	// Parallelism is the number of repo jobs run at once; JobBuffer is how
+	// many requests may wait in the dispatcher.
+	Parallelism int `yaml:"parallelism" env:"INGEST_PARALLELISM" default:"2"`
+	JobBuffer   int `yaml:"job_buffer" env:"INGEST_JOB_BUFFER" default:"100"`
+
+	// FatalStages is a comma-separated stage list; empty keeps the default
+	// and "none" makes every stage non-fatal.
+	FatalStages        string `yaml:"fatal_stages" env:"INGEST_FATAL_STAGES"`
+	IssueTaxonomyPath  string `yaml:"issue_taxonomy_path" env:"ISSUE_TAXONOMY_PATH"`
+	RedactionRulesPath string `yaml:"redaction_rules_path" env:"REDACTION_RULES_PATH"`
+
+	// MergedSymbolPRs is how many merged PRs per fetch get Go symbol
+	// changes; each costs up to two file reads per changed .go file.
+	MergedSymbolPRs int `yaml:"merged_symbol_prs" env:"INGEST_MERGED_SYMBOL_PRS" default:"0"`
+
+	// The re-sync scheduler also needs the GitHub App.
+	SyncScheduler bool          `yaml:"sync_scheduler" env:"SYNC_SCHEDULER" default:"on"`
+	SyncInterval  time.Duration `yaml:"sync_interval" env:"SYNC_INTERVAL" default:"24h"`
+}
+
+func (i *Ingestion) Validate() error {
+	var errs []error
+	if i.Parallelism < 1 {
+		errs = append(errs, errors.New("ingestion.parallelism must be at least 1"))
+	}
+	if i.JobBuffer < 1 {
+		errs = append(errs, errors.New("ingestion.job_buffer must be at least 1"))
+	}
+	if i.MergedSymbolPRs < 0 {
+		errs = append(errs, errors.New("ingestion.merged_symbol_prs must not be negative"))
+	}
+	if i.SyncInterval <= 0 {
+		errs = append(errs, errors.New("ingestion.sync_interval must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Load reads the config from the environment and the optional CONFIG_FILE.
+func Load() (*Config, error) {
+	cfg := &Config{}
+	if err := shared.Load(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
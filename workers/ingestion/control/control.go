@@ -0,0 +1,134 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"codrel-sentinel/workers/ingestion-worker/db"
+	"codrel-sentinel/workers/ingestion-worker/kafka"
+)
+
+// Causes a job context can be cancelled with.
+var (
+	ErrPaused    = errors.New("repo paused")
+	ErrCancelled = errors.New("cancelled by control message")
+	ErrShutdown  = errors.New("worker shutting down")
+)
+
+// Registry tracks the running job of each repo so it can be cancelled from
+// outside the worker goroutine that owns it.
+type Registry struct {
+	mu   sync.Mutex
+	jobs map[string]context.CancelCauseFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{jobs: map[string]context.CancelCauseFunc{}}
+}
+
+// Start derives the job context for a repo. The returned func releases it
+// and must be called when the job ends.
+func (r *Registry) Start(parent context.Context, repo string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	r.mu.Lock()
+	r.jobs[repo] = cancel
+	r.mu.Unlock()
+
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.jobs, repo)
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Cancel stops the running job of a repo. It reports false when this worker
+// is not running one.
+func (r *Registry) Cancel(repo string, cause error) bool {
+	r.mu.Lock()
+	cancel, ok := r.jobs[repo]
+	r.mu.Unlock()
+
+	if ok {
+		log.Printf("[control] cancelling job for %s: %v", repo, cause)
+		cancel(cause)
+	}
+	return ok
+}
+
+// Cause explains why a job context ended. Parent cancellation, which only
+// happens on shutdown, is reported as ErrShutdown.
+func Cause(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, context.Canceled) {
+		return ErrShutdown
+	}
+	return cause
+}
+
+// WatchStatus polls the repo status while a job runs and cancels it once
+// the repo is PAUSED.
+func (r *Registry) WatchStatus(ctx context.Context, repo string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			status, err := db.GetStatus(repo)
+			if err != nil {
+				continue
+			}
+			if status == "PAUSED" {
+				r.Cancel(repo, ErrPaused)
+				return
+			}
+		}
+	}
+}
+
+// Consume reads the control topic until ctx ends and cancels the jobs it
+// names. Messages for repos this worker is not running are ignored.
+func (r *Registry) Consume(ctx context.Context, consumer *ckafka.Consumer) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		msg, err := consumer.ReadMessage(500 * time.Millisecond)
+		if err != nil {
+			continue
+		}
+
+		m, err := kafka.ReadControlMessage(msg)
+		if err != nil || m.Repo == "" {
+			log.Println("[control] invalid control message:", err)
+			continue
+		}
+
+		switch m.Action {
+		case "cancel", "pause":
+			cause := ErrCancelled
+			if m.Action == "pause" {
+				cause = ErrPaused
+			}
+			if m.Reason != "" {
+				cause = fmt.Errorf("%w: %s", cause, m.Reason)
+			}
+			r.Cancel(m.Repo, cause)
+		default:
+			log.Printf("[control] unknown action %q for %s", m.Action, m.Repo)
+		}
+	}
+}
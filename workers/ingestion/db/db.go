@@ -1,24 +1,19 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"hash/fnv"
 	"log"
-	"os"
 	"strings"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 var DB *sql.DB
 
-func InitDB() {
-	_ = godotenv.Load()
-	connStr := os.Getenv("DATABASE_URL")
-
-	if connStr == "" {
-		log.Fatal("DATABASE_URL environment variable is required")
-	}
-
+func InitDB(connStr string) {
 	if !strings.Contains(connStr, "binary_parameters=yes") {
 		if strings.Contains(connStr, "?") {
 			connStr += "&binary_parameters=yes"
@@ -63,5 +58,444 @@ func MarkFailed(repoID string, errMsg string) error {
     WHERE id = $2
   `
 	_, err := DB.Exec(query, errMsg, repoID)
+	if err != nil {
+		log.Printf("❌ Failed to mark %s as FAILED: %v", repoID, err)
+		return err
+	}
+
+	log.Printf("🚫 Repo %s marked as FAILED: %s", repoID, errMsg)
+	return nil
+}
+
+// ClearError drops the failure message left by a previous run.
+func ClearError(repoID string) error {
+	_, err := DB.Exec(`UPDATE repositories SET error = NULL WHERE id = $1`, repoID)
+	return err
+}
+
+// StartStage records that a stage of an ingestion job began and returns the
+// row id used to finish it.
+func StartStage(jobID, repo, stage string) (int64, error) {
+	query := `
+    INSERT INTO ingestion_job_stages (job_id, repo, stage, status, started_at)
+    VALUES ($1, $2, $3, 'RUNNING', NOW())
+    RETURNING id
+  `
+	var id int64
+	err := DB.QueryRow(query, jobID, repo, stage).Scan(&id)
+	return id, err
+}
+
+func FinishStage(id int64, status string, items int, warnings []string, errMsg string) error {
+	query := `
+    UPDATE ingestion_job_stages
+    SET status = $1, items = $2, warnings = $3, error = NULLIF($4, ''),
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM (NOW() - started_at)) * 1000)::int
+    WHERE id = $5
+  `
+	_, err := DB.Exec(query, status, items, pq.Array(warnings), errMsg, id)
+	return err
+}
+
+type CoChange struct {
+	FileA        string
+	FileB        string
+	CoChanges    int
+	ChangesA     int
+	ChangesB     int
+	Support      float64
+	ConfidenceAB float64
+	ConfidenceBA float64
+	Lift         float64
+}
+
+// ReplaceCoChanges swaps the stored file couplings of a repo for a fresh set.
+// An empty set clears them, so callers only pass a set they trust.
+func ReplaceCoChanges(repo string, rows []CoChange) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM file_co_changes WHERE repo = $1`, repo); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+    INSERT INTO file_co_changes (
+      repo, file_a, file_b, co_changes, changes_a, changes_b,
+      support, confidence_ab, confidence_ba, lift, updated_at
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+  `)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range rows {
+		if _, err := stmt.Exec(repo, r.FileA, r.FileB, r.CoChanges, r.ChangesA, r.ChangesB, r.Support, r.ConfidenceAB, r.ConfidenceBA, r.Lift); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func GetStatus(repoID string) (string, error) {
+	var status string
+	err := DB.QueryRow(`SELECT status FROM repositories WHERE id = $1`, repoID).Scan(&status)
+	return status, err
+}
+
+// SaveCheckpoint keeps the finished stage outputs of a stopped job so the
+// next run for the repo can pick up from there. A repo has at most one.
+func SaveCheckpoint(repo, jobID, reason string, state []byte) error {
+	query := `
+    INSERT INTO ingestion_checkpoints (repo, job_id, reason, state, created_at)
+    VALUES ($1, $2, $3, $4, NOW())
+    ON CONFLICT (repo) DO UPDATE
+    SET job_id = EXCLUDED.job_id, reason = EXCLUDED.reason,
+        state = EXCLUDED.state, created_at = NOW()
+  `
+	_, err := DB.Exec(query, repo, jobID, reason, state)
+	return err
+}
+
+// LoadCheckpoint returns the saved state for a repo, or nil if there is none.
+func LoadCheckpoint(repo string) ([]byte, error) {
+	var state []byte
+	err := DB.QueryRow(`SELECT state FROM ingestion_checkpoints WHERE repo = $1`, repo).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return state, err
+}
+
+func DeleteCheckpoint(repo string) error {
+	_, err := DB.Exec(`DELETE FROM ingestion_checkpoints WHERE repo = $1`, repo)
 	return err
-}
\ No newline at end of file
+}
+
+// LoadKeywordCounts returns a repo's stored keyword document frequencies,
+// keyed by the kind of job that produced them.
+func LoadKeywordCounts(repo string) (map[string][]byte, error) {
+	rows, err := DB.Query(`SELECT source, counts FROM keyword_doc_freqs WHERE repo = $1`, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]byte{}
+	for rows.Next() {
+		var source string
+		var counts []byte
+		if err := rows.Scan(&source, &counts); err != nil {
+			return nil, err
+		}
+		out[source] = counts
+	}
+	return out, rows.Err()
+}
+
+// SaveKeywordCounts replaces the stored keyword document frequencies of one
+// source for a repo.
+func SaveKeywordCounts(repo, source string, counts []byte) error {
+	query := `
+    INSERT INTO keyword_doc_freqs (repo, source, counts, updated_at)
+    VALUES ($1, $2, $3, NOW())
+    ON CONFLICT (repo, source) DO UPDATE
+    SET counts = EXCLUDED.counts, updated_at = NOW()
+  `
+	_, err := DB.Exec(query, repo, source, counts)
+	return err
+}
+
+// SyncCandidate is a repo the re-sync scheduler may act on. Scheduled is
+// false until the scheduler has seen the repo once.
+type SyncCandidate struct {
+	Repo      string
+	Status    string
+	UpdatedAt time.Time
+	Scheduled bool
+	Failures  int
+}
+
+// DueForSync lists repos that are not paused and whose next sync time has
+// passed or was never set, most overdue first.
+func DueForSync(limit int) ([]SyncCandidate, error) {
+	query := `
+    SELECT r.id, r.status, COALESCE(r.updated_at, NOW()),
+           s.repo IS NOT NULL, COALESCE(s.failures, 0)
+    FROM repositories r
+    LEFT JOIN repo_sync_schedule s ON s.repo = r.id
+    WHERE r.status <> 'PAUSED'
+      AND (s.next_sync_at IS NULL OR s.next_sync_at <= NOW())
+    ORDER BY s.next_sync_at ASC NULLS FIRST
+    LIMIT $1
+  `
+	rows, err := DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SyncCandidate
+	for rows.Next() {
+		var c SyncCandidate
+		if err := rows.Scan(&c.Repo, &c.Status, &c.UpdatedAt, &c.Scheduled, &c.Failures); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ScheduleSync sets when a repo is next due. enqueued records that a sync
+// request was published now.
+func ScheduleSync(repo string, next time.Time, failures int, enqueued bool) error {
+	query := `
+    INSERT INTO repo_sync_schedule (repo, next_sync_at, failures, last_enqueued_at)
+    VALUES ($1, $2, $3, CASE WHEN $4 THEN NOW() END)
+    ON CONFLICT (repo) DO UPDATE
+    SET next_sync_at = EXCLUDED.next_sync_at,
+        failures = EXCLUDED.failures,
+        last_enqueued_at = COALESCE(EXCLUDED.last_enqueued_at, repo_sync_schedule.last_enqueued_at)
+  `
+	_, err := DB.Exec(query, repo, next, failures, enqueued)
+	return err
+}
+
+// RepoLockKey is the advisory lock key that guards jobs for one repo.
+func RepoLockKey(repo string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("repo:" + repo))
+	return int64(h.Sum64())
+}
+
+// JobLockKey is the advisory lock key a job of one class holds next to the
+// repo lock, so a worker that finds the repo busy can tell what holds it.
+func JobLockKey(repo, class string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("job:" + class + ":" + repo))
+	return int64(h.Sum64())
+}
+
+// TryAdvisoryLock takes a session-level Postgres advisory lock on its own
+// connection. The lock is held for as long as the returned connection stays
+// open; let go with ReleaseAdvisoryLock.
+func TryAdvisoryLock(ctx context.Context, key int64) (*sql.Conn, bool, error) {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	return conn, true, nil
+}
+
+// TryAdvisoryLockOn takes one more advisory lock on a connection returned
+// by TryAdvisoryLock.
+func TryAdvisoryLockOn(ctx context.Context, conn *sql.Conn, key int64) (bool, error) {
+	var ok bool
+	err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok)
+	return ok, err
+}
+
+// Backfill is the progress of a long-range backfill. Cursor is the start of
+// the oldest slice emitted so far; the walk is done once it reaches Since.
+type Backfill struct {
+	Repo      string
+	JobID     string
+	Since     time.Time
+	Cursor    time.Time
+	ChunkDays int
+	Chunks    int
+	Status    string
+	Error     string
+	UpdatedAt time.Time
+}
+
+func LoadBackfill(repo string) (*Backfill, error) {
+	b := &Backfill{Repo: repo}
+	err := DB.QueryRow(`
+    SELECT job_id, since, cursor, chunk_days, chunks, status, COALESCE(error, ''), updated_at
+    FROM ingestion_backfills WHERE repo = $1
+  `, repo).Scan(&b.JobID, &b.Since, &b.Cursor, &b.ChunkDays, &b.Chunks, &b.Status, &b.Error, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func SaveBackfill(b *Backfill) error {
+	query := `
+    INSERT INTO ingestion_backfills (repo, job_id, since, cursor, chunk_days, chunks, status, error, created_at, updated_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NOW(), NOW())
+    ON CONFLICT (repo) DO UPDATE
+    SET job_id = EXCLUDED.job_id, since = EXCLUDED.since, cursor = EXCLUDED.cursor,
+        chunk_days = EXCLUDED.chunk_days, chunks = EXCLUDED.chunks,
+        status = EXCLUDED.status, error = EXCLUDED.error, updated_at = NOW()
+  `
+	_, err := DB.Exec(query, b.Repo, b.JobID, b.Since, b.Cursor, b.ChunkDays, b.Chunks, b.Status, b.Error)
+	return err
+}
+
+// QueueBackfill marks a backfill as requested again, so it is not picked up
+// twice before a worker starts on it.
+func QueueBackfill(repo string) error {
+	_, err := DB.Exec(`UPDATE ingestion_backfills SET status = 'QUEUED', updated_at = NOW() WHERE repo = $1`, repo)
+	return err
+}
+
+// StalledBackfills lists backfills to queue again: RUNNING or QUEUED ones
+// that have not moved for staleAfter, left behind by a worker that died or a
+// lost request, and PAUSED ones whose repo is no longer paused.
+func StalledBackfills(staleAfter time.Duration, limit int) ([]string, error) {
+	query := `
+    SELECT b.repo
+    FROM ingestion_backfills b
+    JOIN repositories r ON r.id = b.repo
+    WHERE r.status <> 'PAUSED'
+      AND ((b.status IN ('RUNNING', 'QUEUED') AND b.updated_at < NOW() - make_interval(secs => $1))
+        OR b.status = 'PAUSED')
+    ORDER BY b.updated_at ASC
+    LIMIT $2
+  `
+	rows, err := DB.Query(query, staleAfter.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var repo string
+		if err := rows.Scan(&repo); err != nil {
+			return nil, err
+		}
+		out = append(out, repo)
+	}
+	return out, rows.Err()
+}
+
+// RegisterRepo records a repo connected through an org request and marks it
+// QUEUED, the same way the dashboard does for a single connection.
+func RegisterRepo(owner, name, installationID, connectedBy string) error {
+	query := `
+    INSERT INTO repositories (id, name, owner, full_name, installation_id, connected_by, status)
+    VALUES ($1, $2, $3, $1, $4, $5, 'QUEUED')
+    ON CONFLICT (id) DO UPDATE
+    SET status = 'QUEUED', updated_at = NOW()
+  `
+	_, err := DB.Exec(query, owner+"/"+name, name, owner, installationID, connectedBy)
+	return err
+}
+
+// GetRepoState returns a repo's status and last error. An unknown repo has
+// an empty status.
+func GetRepoState(repoID string) (string, string, error) {
+	var status, errMsg string
+	err := DB.QueryRow(`SELECT status, COALESCE(error, '') FROM repositories WHERE id = $1`, repoID).Scan(&status, &errMsg)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	return status, errMsg, err
+}
+
+func StartOrgIngestion(jobID, org, installationID string, filter []byte) error {
+	query := `
+    INSERT INTO org_ingestions (job_id, org, installation_id, status, filter, created_at, updated_at)
+    VALUES ($1, $2, $3, 'RUNNING', $4, NOW(), NOW())
+  `
+	_, err := DB.Exec(query, jobID, org, installationID, filter)
+	return err
+}
+
+// LoadOrgIngestion returns the saved summary of an org job, or nil if there
+// is none.
+func LoadOrgIngestion(jobID string) ([]byte, error) {
+	var summary []byte
+	err := DB.QueryRow(`SELECT summary FROM org_ingestions WHERE job_id = $1`, jobID).Scan(&summary)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return summary, err
+}
+
+// StalledOrg is an org job the scheduler should queue again.
+type StalledOrg struct {
+	JobID          string
+	Org            string
+	InstallationID string
+	Filter         []byte
+}
+
+// StalledOrgIngestions lists org jobs to resume: INTERRUPTED ones, whose
+// worker could not queue them on shutdown, and RUNNING or QUEUED ones that
+// have not saved progress for staleAfter, left behind by a worker that died
+// or a lost request.
+func StalledOrgIngestions(staleAfter time.Duration, limit int) ([]StalledOrg, error) {
+	query := `
+    SELECT job_id, org, installation_id, filter
+    FROM org_ingestions
+    WHERE status = 'INTERRUPTED'
+       OR (status IN ('RUNNING', 'QUEUED') AND updated_at < NOW() - make_interval(secs => $1))
+    ORDER BY updated_at ASC
+    LIMIT $2
+  `
+	rows, err := DB.Query(query, staleAfter.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StalledOrg
+	for rows.Next() {
+		var o StalledOrg
+		if err := rows.Scan(&o.JobID, &o.Org, &o.InstallationID, &o.Filter); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// QueueOrgIngestion marks an org job as requested again, so it is not picked
+// up twice before a worker resumes it.
+func QueueOrgIngestion(jobID string) error {
+	_, err := DB.Exec(`UPDATE org_ingestions SET status = 'QUEUED', updated_at = NOW() WHERE job_id = $1`, jobID)
+	return err
+}
+
+// SaveOrgIngestion stores the latest summary of an org job. A status other
+// than RUNNING or QUEUED also sets finished_at.
+func SaveOrgIngestion(jobID, status string, summary []byte) error {
+	query := `
+    UPDATE org_ingestions
+    SET status = $2, summary = $3, updated_at = NOW(),
+        finished_at = CASE WHEN $2 NOT IN ('RUNNING', 'QUEUED') THEN NOW() END
+    WHERE job_id = $1
+  `
+	_, err := DB.Exec(query, jobID, status, summary)
+	return err
+}
+
+// ReleaseAdvisoryLock unlocks keys and closes conn. Closing alone would put
+// the connection back in the pool with the locks still held.
+func ReleaseAdvisoryLock(conn *sql.Conn, keys ...int64) {
+	for _, key := range keys {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			log.Printf("advisory unlock %d failed: %v", key, err)
+		}
+	}
+	conn.Close()
+}
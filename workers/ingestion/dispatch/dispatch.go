@@ -0,0 +1,180 @@
+package dispatch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// recheck bounds how long Next sleeps, so items held back by Retry are
+// picked up once their delay has passed.
+const recheck = time.Second
+
+// Item is one queued request. Tenant is whoever the work is done for; Repo
+// is what it touches, and no two items for one repo run at once.
+type Item struct {
+	Tenant string
+	Repo   string
+	Kind   string
+	Value  any
+
+	notBefore time.Time
+}
+
+// Dispatcher hands queued items to workers fairly across tenants. Each
+// tenant has its own FIFO queue; the next item goes to the tenant with the
+// fewest items running, and among equals to the one served longest ago, so
+// one tenant with a large backlog cannot hold every worker.
+type Dispatcher struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	queues   map[string][]*Item
+	// rotation lists tenants with queued items, least recently served first.
+	rotation []string
+	active   map[string]int
+	running  map[string]bool
+	changed  chan struct{}
+}
+
+func New(capacity int) *Dispatcher {
+	return &Dispatcher{
+		capacity: capacity,
+		queues:   map[string][]*Item{},
+		active:   map[string]int{},
+		running:  map[string]bool{},
+		changed:  make(chan struct{}),
+	}
+}
+
+// Full reports whether the queue has reached capacity; the caller should
+// stop reading new requests until it drains.
+func (d *Dispatcher) Full() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size >= d.capacity
+}
+
+// Push queues it behind the tenant's earlier items. An item with the same
+// repo and kind as one still waiting is dropped, which absorbs redeliveries
+// and repeated requests; Push reports whether it was queued.
+func (d *Dispatcher) Push(it *Item) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, q := range d.queues[it.Tenant] {
+		if q.Repo == it.Repo && q.Kind == it.Kind {
+			return false
+		}
+	}
+	d.enqueue(it)
+	return true
+}
+
+// Next blocks until an item can run and returns it, or returns false once
+// ctx ends. The item must be handed back with Done or Retry.
+func (d *Dispatcher) Next(ctx context.Context) (*Item, bool) {
+	for {
+		d.mu.Lock()
+		it := d.pick(time.Now())
+		changed := d.changed
+		d.mu.Unlock()
+
+		if it != nil {
+			return it, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-changed:
+		case <-time.After(recheck):
+		}
+	}
+}
+
+// Done marks a running item finished.
+func (d *Dispatcher) Done(it *Item) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.finish(it)
+}
+
+// Retry finishes a running item and queues it again to run no sooner than
+// after, at the back of its tenant's queue.
+func (d *Dispatcher) Retry(it *Item, after time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.finish(it)
+	it.notBefore = time.Now().Add(after)
+	d.enqueue(it)
+}
+
+func (d *Dispatcher) enqueue(it *Item) {
+	if len(d.queues[it.Tenant]) == 0 {
+		d.rotation = append(d.rotation, it.Tenant)
+	}
+	d.queues[it.Tenant] = append(d.queues[it.Tenant], it)
+	d.size++
+	d.notify()
+}
+
+func (d *Dispatcher) finish(it *Item) {
+	delete(d.running, it.Repo)
+	if d.active[it.Tenant]--; d.active[it.Tenant] <= 0 {
+		delete(d.active, it.Tenant)
+	}
+	d.notify()
+}
+
+// pick takes the next runnable item: from each tenant the oldest item whose
+// repo is idle and whose delay has passed, then the tenant with the fewest
+// running items, earliest in the rotation on a tie.
+func (d *Dispatcher) pick(now time.Time) *Item {
+	best, bestIdx := -1, -1
+	for i, tenant := range d.rotation {
+		idx := d.runnable(d.queues[tenant], now)
+		if idx < 0 {
+			continue
+		}
+		if best < 0 || d.active[tenant] < d.active[d.rotation[best]] {
+			best, bestIdx = i, idx
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	tenant := d.rotation[best]
+	q := d.queues[tenant]
+	it := q[bestIdx]
+	q = append(q[:bestIdx], q[bestIdx+1:]...)
+	d.size--
+
+	d.rotation = append(d.rotation[:best], d.rotation[best+1:]...)
+	if len(q) == 0 {
+		delete(d.queues, tenant)
+	} else {
+		d.queues[tenant] = q
+		d.rotation = append(d.rotation, tenant)
+	}
+
+	d.active[tenant]++
+	d.running[it.Repo] = true
+	return it
+}
+
+func (d *Dispatcher) runnable(q []*Item, now time.Time) int {
+	for i, it := range q {
+		if !d.running[it.Repo] && !now.Before(it.notBefore) {
+			return i
+		}
+	}
+	return -1
+}
+
+// notify wakes every goroutine waiting in Next.
+func (d *Dispatcher) notify() {
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
@@ -23,8 +23,7 @@ type ArchFile struct {
 }
 
 
-func FetchRepoArchitecture(client *github.Client, owner, repo string) ([]ArchFile, error) {
-	ctx := context.Background()
+func FetchRepoArchitecture(ctx context.Context, client *github.Client, owner, repo string) ([]ArchFile, error) {
 	log.Printf("[ingest] architecture scan: %s/%s", owner, repo)
 
 	_, directoryContent, _, err := client.Repositories.GetContents(ctx, owner, repo, "", nil)
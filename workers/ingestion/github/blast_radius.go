@@ -0,0 +1,93 @@
+package github
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+
+	"codrel-sentinel/workers/shared/depgraph"
+)
+
+const (
+	blastRadiusLimit = 25
+	archiveTimeout   = 2 * time.Minute
+)
+
+// FetchImportGraph builds the import graph of the default branch from a
+// single tarball download. The tarball lists every path in the tree, so no
+// separate tree listing or per-file contents requests are needed.
+func FetchImportGraph(ctx context.Context, client *github.Client, owner, repo string) (*depgraph.Graph, error) {
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+
+	log.Printf("[ingest] building import graph for %s/%s", owner, repo)
+
+	r, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	link, _, err := client.Repositories.GetArchiveLink(
+		ctx,
+		owner,
+		repo,
+		github.Tarball,
+		&github.RepositoryContentGetOptions{Ref: r.GetDefaultBranch()},
+		3,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g := depgraph.New()
+	if err := g.LoadURL(ctx, link.String()); err != nil {
+		return nil, err
+	}
+	g.Resolve()
+
+	log.Printf("[ingest] import graph built from %d file(s)", g.Size())
+	return g, nil
+}
+
+func prPaths(p *MinimalPR) []string {
+	out := append([]string(nil), p.Files...)
+	for _, f := range p.DiffFiles {
+		out = append(out, f.Path)
+	}
+	for _, f := range p.OriginalDiffFiles {
+		out = append(out, f.Path)
+	}
+	return out
+}
+
+func crashPaths(g *depgraph.Graph, c *WorkflowCrash) []string {
+	var out []string
+	for _, f := range c.ErrorFiles {
+		if m := g.Match(f); m != "" {
+			out = append(out, m)
+		}
+	}
+	for _, f := range c.Change.Files {
+		out = append(out, f.Filename)
+	}
+	return out
+}
+
+// AnnotateBlastRadius attaches the transitive dependents of every touched
+// file to each crash and PR.
+func AnnotateBlastRadius(g *depgraph.Graph, crashes []WorkflowCrash, prs ...[]MinimalPR) {
+	if g == nil {
+		return
+	}
+
+	for i := range crashes {
+		crashes[i].BlastRadius = g.Radius(crashPaths(g, &crashes[i]), blastRadiusLimit)
+	}
+	for _, bucket := range prs {
+		for i := range bucket {
+			bucket[i].BlastRadius = g.Radius(prPaths(&bucket[i]), blastRadiusLimit)
+		}
+	}
+}
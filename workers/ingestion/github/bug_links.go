@@ -0,0 +1,181 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const maxLinkedBugs = 50
+
+type IssueFix struct {
+	Source          string    `json:"source"`
+	PRNumber        int       `json:"pr_number,omitempty"`
+	PRURL           string    `json:"pr_url,omitempty"`
+	CommitSHA       string    `json:"commit_sha,omitempty"`
+	Files           []string  `json:"files,omitempty"`
+	FixedAt         time.Time `json:"fixed_at"`
+	FixLatencyHours float64   `json:"fix_latency_hours"`
+}
+
+func closingKeywordRegex(number int) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(
+		`(?i)\b(close[sd]?|fix(e[sd])?|resolve[sd]?)\s*:?\s+(?:[\w.-]+/[\w.-]+)?#%d\b`,
+		number,
+	))
+}
+
+func isBugIssue(i *Issue) bool {
+	switch i.IssueType {
+	case "bug", "regression", "security", "performance":
+		return true
+	}
+	return i.ChangeHint == "bugfix"
+}
+
+// linkBugFixes annotates closed bug issues with the PR or commit that fixed
+// them, found through the issue timeline.
+func linkBugFixes(ctx context.Context, client *github.Client, owner, repo string, issues []Issue) {
+	linked := 0
+	for i := range issues {
+		if linked >= maxLinkedBugs {
+			break
+		}
+		if !isBugIssue(&issues[i]) {
+			continue
+		}
+		linked++
+
+		fix, err := findIssueFix(ctx, client, owner, repo, &issues[i])
+		if err != nil {
+			log.Printf("[ingest] fix lookup failed for issue #%d: %v", issues[i].Number, err)
+			continue
+		}
+		issues[i].Fix = fix
+	}
+}
+
+// findIssueFix prefers an explicit "closed by commit" event, then a merged PR
+// that cross-references the issue with a closing keyword, then any merged PR
+// that cross-references it.
+func findIssueFix(ctx context.Context, client *github.Client, owner, repo string, issue *Issue) (*IssueFix, error) {
+	timeline, _, err := client.Issues.ListIssueTimeline(
+		ctx,
+		owner,
+		repo,
+		issue.Number,
+		&github.ListOptions{PerPage: 100},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		closedBySHA string
+		keywordPR   *github.PullRequest
+		mentionPR   *github.PullRequest
+	)
+	keyword := closingKeywordRegex(issue.Number)
+
+	for _, t := range timeline {
+		switch t.GetEvent() {
+		case "closed":
+			if t.GetCommitID() != "" {
+				closedBySHA = t.GetCommitID()
+			}
+		case "cross-referenced":
+			src := t.GetSource().GetIssue()
+			if src == nil || !src.IsPullRequest() {
+				continue
+			}
+			if r := src.GetRepository(); r != nil && !strings.EqualFold(r.GetFullName(), owner+"/"+repo) {
+				continue
+			}
+
+			pr, _, err := client.PullRequests.Get(ctx, owner, repo, src.GetNumber())
+			if err != nil || pr.MergedAt == nil {
+				continue
+			}
+			if keywordPR == nil && keyword.MatchString(pr.GetTitle()+"\n"+pr.GetBody()) {
+				keywordPR = pr
+			} else if mentionPR == nil {
+				mentionPR = pr
+			}
+		}
+	}
+
+	if closedBySHA != "" {
+		fix := &IssueFix{Source: "closed_by_commit", CommitSHA: closedBySHA}
+
+		if prs, _, err := client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, closedBySHA, nil); err == nil {
+			for _, pr := range prs {
+				if pr.MergedAt != nil {
+					return prFix(ctx, client, owner, repo, issue, pr, "closed_by_commit")
+				}
+			}
+		}
+
+		commit, _, err := client.Repositories.GetCommit(ctx, owner, repo, closedBySHA, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range commit.Files {
+			fix.Files = append(fix.Files, f.GetFilename())
+		}
+		fix.FixedAt = commit.GetCommit().GetCommitter().GetDate().Time
+		fix.FixLatencyHours = fix.FixedAt.Sub(issue.CreatedAt).Hours()
+		return fix, nil
+	}
+
+	if keywordPR != nil {
+		return prFix(ctx, client, owner, repo, issue, keywordPR, "closing_keyword")
+	}
+	if mentionPR != nil {
+		return prFix(ctx, client, owner, repo, issue, mentionPR, "cross_reference")
+	}
+
+	return nil, nil
+}
+
+func prFix(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	issue *Issue,
+	pr *github.PullRequest,
+	source string,
+) (*IssueFix, error) {
+
+	fix := &IssueFix{
+		Source:    source,
+		PRNumber:  pr.GetNumber(),
+		PRURL:     pr.GetHTMLURL(),
+		CommitSHA: pr.GetMergeCommitSHA(),
+	}
+	if pr.MergedAt != nil {
+		fix.FixedAt = pr.MergedAt.Time
+		fix.FixLatencyHours = fix.FixedAt.Sub(issue.CreatedAt).Hours()
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		files, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, pr.GetNumber(), opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			fix.Files = append(fix.Files, f.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return fix, nil
+}
@@ -1,15 +1,21 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
+	neturl "net/url"
 	"time"
+
+	"codrel-sentinel/workers/ingestion-worker/keywords"
 )
 
+// MaxClosedIssues is the single page of closed issues read per job.
+const MaxClosedIssues = 100
+
 type User struct {
 	Login string `json:"login"`
 	Type  string `json:"type"`
@@ -36,23 +42,49 @@ type Issue struct {
 	} `json:"pull_request,omitempty"`
 
 	IssueType  string   `json:"issue_type"`
+	Severity   string   `json:"severity"`
+	Component  string   `json:"component,omitempty"`
+	TypeSource string   `json:"type_source"`
 	ChangeHint string   `json:"change_hint"`
 	Keywords   []string `json:"keywords"`
 	TimeBucket string   `json:"time_bucket"`
+
+	Fix *IssueFix `json:"fix,omitempty"`
 }
 
 func FetchClosedIssuesRaw(
+	ctx context.Context,
+	token string,
+	owner string,
+	repo string,
+) ([]Issue, error) {
+	return FetchClosedIssuesRawIn(ctx, token, owner, repo, Window{})
+}
+
+// FetchClosedIssuesRawIn reads one page of closed issues. A bounded window
+// searches by close date; otherwise the latest closed issues are read
+// whatever their age.
+func FetchClosedIssuesRawIn(
+	ctx context.Context,
 	token string,
 	owner string,
 	repo string,
+	w Window,
 ) ([]Issue, error) {
 
 	url := fmt.Sprintf(
-		"https://api.github.com/repos/%s/%s/issues?state=closed&per_page=100",
-		owner, repo,
+		"https://api.github.com/repos/%s/%s/issues?state=closed&per_page=%d",
+		owner, repo, MaxClosedIssues,
 	)
+	if w.Bounded() {
+		url = fmt.Sprintf(
+			"https://api.github.com/search/issues?q=%s&per_page=%d",
+			neturl.QueryEscape(fmt.Sprintf("repo:%s/%s is:issue is:closed closed:%s", owner, repo, w.searchRange())),
+			MaxClosedIssues,
+		)
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +98,9 @@ func FetchClosedIssuesRaw(
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
 
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -73,7 +108,15 @@ func FetchClosedIssuesRaw(
 	}
 
 	var issues []Issue
-	if err := json.Unmarshal(raw, &issues); err != nil {
+	if w.Bounded() {
+		var found struct {
+			Items []Issue `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &found); err != nil {
+			return nil, err
+		}
+		issues = found.Items
+	} else if err := json.Unmarshal(raw, &issues); err != nil {
 		return nil, err
 	}
 
@@ -87,85 +130,52 @@ func FetchClosedIssuesRaw(
 			continue
 		}
 
-		issues[i].IssueType = detectIssueType(&issues[i])
+		class := Taxonomy.Classify(&issues[i])
+		issues[i].IssueType = class.Type
+		issues[i].Severity = class.Severity
+		issues[i].Component = class.Component
+		issues[i].TypeSource = class.Source
 		issues[i].ChangeHint = detectChangeHint(issues[i].Title, issues[i].Body)
-		issues[i].Keywords = extractKeywords(issues[i].Title, issues[i].Body)
+		issues[i].Keywords = keywords.Extract(issueText(&issues[i]), keywordLimit)
 		issues[i].TimeBucket = timeBucket(issues[i].ClosedAt, now)
 
 		filtered = append(filtered, issues[i])
 	}
 
+	linkBugFixes(ctx, NewClient(token), owner, repo, filtered)
+
 	_ = writeJSON("bugs.json", filtered)
 	log.Printf("[raw] HTTP %d | total=%d", resp.StatusCode, len(filtered))
 
 	return filtered, nil
 }
 
-func detectIssueType(i *Issue) string {
-	if i.PullRequest != nil {
-		return "pr-linked"
-	}
-	return "issue"
-}
-
 func detectChangeHint(title, body string) string {
-	t := strings.ToLower(title + " " + body)
+	t := newTokenSet(title + " " + body)
+
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if t.has(w) {
+				return true
+			}
+		}
+		return false
+	}
 
 	switch {
-	case strings.Contains(t, "fix"),
-		strings.Contains(t, "bug"),
-		strings.Contains(t, "error"),
-		strings.Contains(t, "crash"):
+	case has("fix", "fixes", "fixed", "bug", "bugs", "error", "errors", "crash", "crashes"):
 		return "bugfix"
-	case strings.Contains(t, "feat"),
-		strings.Contains(t, "feature"),
-		strings.Contains(t, "add"):
+	case has("feat", "feature", "features", "add", "adds", "added"):
 		return "feature"
-	case strings.Contains(t, "refactor"),
-		strings.Contains(t, "cleanup"),
-		strings.Contains(t, "restructure"):
+	case has("refactor", "refactoring", "cleanup", "restructure"):
 		return "refactor"
-	case strings.Contains(t, "test"),
-		strings.Contains(t, "jest"),
-		strings.Contains(t, "ci"):
+	case has("test", "tests", "testing", "jest", "ci"):
 		return "test"
 	default:
 		return "other"
 	}
 }
 
-func extractKeywords(title, body string) []string {
-	text := strings.ToLower(title + " " + body)
-
-	stop := map[string]bool{
-		"the": true, "and": true, "or": true, "to": true,
-		"a": true, "of": true, "in": true, "on": true,
-		"for": true, "with": true, "is": true,
-	}
-
-	words := strings.FieldsFunc(text, func(r rune) bool {
-		return r < 'a' || r > 'z'
-	})
-
-	seen := map[string]bool{}
-	var out []string
-
-	for _, w := range words {
-		if len(w) < 3 || stop[w] {
-			continue
-		}
-		if !seen[w] {
-			seen[w] = true
-			out = append(out, w)
-		}
-		if len(out) >= 8 {
-			break
-		}
-	}
-
-	return out
-}
-
 func timeBucket(closed *time.Time, now time.Time) string {
 	if closed == nil {
 		return "unknown"
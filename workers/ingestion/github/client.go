@@ -1,10 +1,67 @@
 package github
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/google/go-github/v61/github"
 )
 
+// WindowMonths is how far back the fetchers look.
+const WindowMonths = 3
+
+// WindowStart is the oldest point in time the fetchers read.
+func WindowStart() time.Time {
+	return time.Now().AddDate(0, -WindowMonths, 0)
+}
+
+// Window is the span of closed/created times a fetch reads. A zero Until
+// means up to now, which is the regular rolling window; a bounded window is
+// one slice of a backfill.
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+// DefaultWindow is the rolling window of the last WindowMonths.
+func DefaultWindow() Window {
+	return Window{Since: WindowStart()}
+}
+
+// Bounded reports whether the window has an upper end.
+func (w Window) Bounded() bool {
+	return !w.Until.IsZero()
+}
+
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Since) && (w.Until.IsZero() || t.Before(w.Until))
+}
+
+// searchRange renders the window as a GitHub search date range. Search
+// ranges are inclusive, so the end is pulled back a second.
+func (w Window) searchRange() string {
+	until := w.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	return w.Since.UTC().Format(time.RFC3339) + ".." + until.Add(-time.Second).UTC().Format(time.RFC3339)
+}
 
 func NewClient(token string) *github.Client {
 	return github.NewClient(nil).WithAuthToken(token)
-}
\ No newline at end of file
+}
+
+// ErrUnauthorized is returned by the raw fetchers when GitHub rejects the
+// token.
+var ErrUnauthorized = errors.New("github rejected the access token")
+
+// IsAuthError reports whether err comes from GitHub rejecting the token, as
+// happens when an installation token expires during a long job.
+func IsAuthError(err error) bool {
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		return ghErr.Response.StatusCode == http.StatusUnauthorized
+	}
+	return errors.Is(err, ErrUnauthorized)
+}
@@ -0,0 +1,65 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// comparePageSize is the number of commits asked for per compare page.
+const comparePageSize = 100
+
+// compareTail returns the newest n commits between base and head, oldest
+// first, and the number of commits in the whole range. The compare endpoint
+// lists commits oldest first, so a range longer than a page is read from its
+// last page backwards.
+func compareTail(ctx context.Context, client *github.Client, owner, repo, base, head string, n int) ([]*github.RepositoryCommit, int, error) {
+	cmp, _, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{PerPage: comparePageSize})
+	if err != nil {
+		return nil, 0, err
+	}
+	total := max(cmp.GetTotalCommits(), len(cmp.Commits))
+	if total <= len(cmp.Commits) {
+		return tail(cmp.Commits, n), total, nil
+	}
+
+	var commits []*github.RepositoryCommit
+	for page := (total + comparePageSize - 1) / comparePageSize; page >= 1 && len(commits) < n; page-- {
+		if page == 1 {
+			commits = append(cmp.Commits, commits...)
+			break
+		}
+		next, _, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{Page: page, PerPage: comparePageSize})
+		if err != nil {
+			return nil, 0, err
+		}
+		commits = append(next.Commits, commits...)
+	}
+	return tail(commits, n), total, nil
+}
+
+// compareAll returns the comparison of base and head with the commits of up to
+// maxPages pages. The compare endpoint returns only its first page of commits
+// unless asked for the rest, which cut off PRs shipped in large releases.
+func compareAll(ctx context.Context, client *github.Client, owner, repo, base, head string, maxPages int) (*github.CommitsComparison, error) {
+	cmp, resp, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{PerPage: comparePageSize})
+	if err != nil {
+		return nil, err
+	}
+	for page := 2; page <= maxPages && resp.NextPage != 0; page++ {
+		next, r, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{Page: resp.NextPage, PerPage: comparePageSize})
+		if err != nil {
+			return nil, err
+		}
+		cmp.Commits = append(cmp.Commits, next.Commits...)
+		resp = r
+	}
+	return cmp, nil
+}
+
+func tail(commits []*github.RepositoryCommit, n int) []*github.RepositoryCommit {
+	if len(commits) > n {
+		return commits[len(commits)-n:]
+	}
+	return commits
+}
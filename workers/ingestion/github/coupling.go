@@ -0,0 +1,170 @@
+package github
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// Changes touching more files than this are bulk edits (renames,
+	// formatting, dependency bumps) and say nothing about coupling.
+	maxChangeSetFiles = 40
+	minCoChanges      = 3
+	minCoConfidence   = 0.5
+	maxCouplings      = 500
+
+	// rebaseSlack is how far from a PR's merge time its rebased commits may
+	// land on the default branch.
+	rebaseSlack = 2 * time.Minute
+)
+
+// FileCoupling is a pair of files that tend to change together. ConfidenceAB
+// is the share of changes to FileA that also touched FileB; Lift above 1
+// means they co-change more often than their individual rates predict.
+type FileCoupling struct {
+	FileA        string  `json:"file_a"`
+	FileB        string  `json:"file_b"`
+	CoChanges    int     `json:"co_changes"`
+	ChangesA     int     `json:"changes_a"`
+	ChangesB     int     `json:"changes_b"`
+	Support      float64 `json:"support"`
+	ConfidenceAB float64 `json:"confidence_ab"`
+	ConfidenceBA float64 `json:"confidence_ba"`
+	Lift         float64 `json:"lift"`
+}
+
+// ComputeCoChanges mines file pairs from merged PR file lists and from the
+// default-branch history. A PR counts once, as its file list, so history
+// commits that belong to one of the PRs are skipped: its squash commit, its
+// branch commits kept by a merge commit, and the commits a rebase merge
+// replays onto the branch.
+func ComputeCoChanges(history []HistoryCommit, merged []MinimalPR) []FileCoupling {
+	var sets [][]string
+	prCommits := map[string]bool{}
+
+	for _, pr := range merged {
+		if pr.MergeCommitSHA != "" {
+			prCommits[pr.MergeCommitSHA] = true
+		}
+		for _, sha := range pr.CommitSHAs {
+			prCommits[sha] = true
+		}
+		sets = append(sets, pr.Files)
+	}
+	for _, c := range history {
+		if prCommits[c.SHA] || rebasedFromPR(c, merged) {
+			continue
+		}
+		files := make([]string, 0, len(c.Files))
+		for _, f := range c.Files {
+			files = append(files, f.Path)
+		}
+		sets = append(sets, files)
+	}
+
+	type pair struct{ a, b string }
+
+	changes := map[string]int{}
+	together := map[pair]int{}
+	total := 0
+
+	for _, set := range sets {
+		files := dedupeSorted(set)
+		if len(files) == 0 || len(files) > maxChangeSetFiles {
+			continue
+		}
+		total++
+		for i, a := range files {
+			changes[a]++
+			for _, b := range files[i+1:] {
+				together[pair{a, b}]++
+			}
+		}
+	}
+
+	var out []FileCoupling
+	for p, n := range together {
+		if n < minCoChanges {
+			continue
+		}
+		ca, cb := changes[p.a], changes[p.b]
+		c := FileCoupling{
+			FileA:        p.a,
+			FileB:        p.b,
+			CoChanges:    n,
+			ChangesA:     ca,
+			ChangesB:     cb,
+			Support:      round2(float64(n) / float64(total)),
+			ConfidenceAB: round2(float64(n) / float64(ca)),
+			ConfidenceBA: round2(float64(n) / float64(cb)),
+			Lift:         round2(float64(n) * float64(total) / float64(ca*cb)),
+		}
+		if c.ConfidenceAB < minCoConfidence && c.ConfidenceBA < minCoConfidence {
+			continue
+		}
+		out = append(out, c)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CoChanges != out[j].CoChanges {
+			return out[i].CoChanges > out[j].CoChanges
+		}
+		if out[i].FileA != out[j].FileA {
+			return out[i].FileA < out[j].FileA
+		}
+		return out[i].FileB < out[j].FileB
+	})
+	if len(out) > maxCouplings {
+		out = out[:maxCouplings]
+	}
+
+	_ = writeJSON("co_changes.json", out)
+	return out
+}
+
+// rebasedFromPR reports whether c looks like a commit a rebase merge put on
+// the branch: rebasing gives it a new SHA, but it is committed when the PR
+// merged and touches only files the PR changed.
+func rebasedFromPR(c HistoryCommit, merged []MinimalPR) bool {
+	if len(c.Files) == 0 {
+		return false
+	}
+	for _, pr := range merged {
+		if pr.MergedAt == nil {
+			continue
+		}
+		if d := c.CommittedAt.Sub(*pr.MergedAt); d < -rebaseSlack || d > rebaseSlack {
+			continue
+		}
+		if containsAll(pr.Files, c.Files) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(prFiles []string, files []FileChurn) bool {
+	in := make(map[string]bool, len(prFiles))
+	for _, f := range prFiles {
+		in[f] = true
+	}
+	for _, f := range files {
+		if !in[f.Path] {
+			return false
+		}
+	}
+	return true
+}
+
+func dedupeSorted(in []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
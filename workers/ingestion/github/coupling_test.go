@@ -0,0 +1,39 @@
+package github
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeCoChangesCountsPRsOnce(t *testing.T) {
+	merged := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := []string{"api/server.go", "api/routes.go"}
+	churn := []FileChurn{{Path: "api/server.go"}, {Path: "api/routes.go"}}
+
+	var prs []MinimalPR
+	var history []HistoryCommit
+	for i := 0; i < 3; i++ {
+		at := merged.Add(time.Duration(i) * 24 * time.Hour)
+		prs = append(prs, MinimalPR{
+			MergedAt:   &at,
+			Files:      files,
+			CommitSHAs: []string{"branch" + string(rune('a'+i))},
+		})
+		history = append(history,
+			// A merge-commit merge keeps the branch commit.
+			HistoryCommit{SHA: "branch" + string(rune('a'+i)), CommittedAt: at.Add(-time.Hour), Files: churn},
+			// A rebase merge replays it under a new SHA at merge time.
+			HistoryCommit{SHA: "rebased" + string(rune('a'+i)), CommittedAt: at.Add(30 * time.Second), Files: churn},
+		)
+	}
+	// A direct push at another time is a change of its own.
+	history = append(history, HistoryCommit{SHA: "direct", CommittedAt: merged.Add(time.Hour), Files: churn})
+
+	pairs := ComputeCoChanges(history, prs)
+	if len(pairs) != 1 {
+		t.Fatalf("pairs = %+v, want one", pairs)
+	}
+	if p := pairs[0]; p.CoChanges != 4 || p.ChangesA != 4 || p.ChangesB != 4 {
+		t.Errorf("pair = %+v, want 4 co-changes from 3 PRs and 1 direct commit", p)
+	}
+}
@@ -1,22 +1,39 @@
 package github
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
-	"io"
 	"log"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v61/github"
+
+	"codrel-sentinel/workers/ingestion-worker/keywords"
+	"codrel-sentinel/workers/shared/depgraph"
+	"codrel-sentinel/workers/shared/diff"
 )
 
+// MaxWorkflowFailures caps the failed runs collected per job.
+const MaxWorkflowFailures = 20
+
 type CodeChange struct {
-	Filename string `json:"filename"`
-	Patch    string `json:"patch"`
+	Filename  string       `json:"filename"`
+	Patch     string       `json:"patch"`
+	Added     []diff.Range `json:"added,omitempty"`
+	Removed   []diff.Range `json:"removed,omitempty"`
+	Functions []string     `json:"functions,omitempty"`
+}
+
+func newCodeChange(filename, patch string) CodeChange {
+	hunks := diff.ParseHunks(patch)
+	return CodeChange{
+		Filename:  filename,
+		Patch:     patch,
+		Added:     diff.AddedRanges(hunks),
+		Removed:   diff.RemovedRanges(hunks),
+		Functions: diff.Functions(hunks),
+	}
 }
 
 type ChangeContext struct {
@@ -32,6 +49,9 @@ type WorkflowCrash struct {
 	ErrorSignature string   `json:"error_signature"`
 	ErrorFiles     []string `json:"error_files,omitempty"`
 	ErrorLines     []string `json:"error_lines,omitempty"`
+	// LogTruncated is set when the log was over the size limits and the
+	// error context comes from its first part only.
+	LogTruncated bool `json:"log_truncated,omitempty"`
 
 	HTMLURL   string    `json:"html_url"`
 	CreatedAt time.Time `json:"created_at"`
@@ -40,13 +60,24 @@ type WorkflowCrash struct {
 	HeadSHA   string `json:"head_sha"`
 	CommitMsg string `json:"commit_msg"`
 
+	Keywords []string `json:"keywords,omitempty"`
+
 	Change ChangeContext `json:"change"`
+
+	LastGreenSHA string          `json:"last_green_sha,omitempty"`
+	Culprits     []CulpritCommit `json:"culprits,omitempty"`
+	// CulpritsSkipped counts older commits since the last green run that
+	// were not ranked; the real culprit may be among them.
+	CulpritsSkipped int `json:"culprits_skipped,omitempty"`
+
+	BlastRadius *depgraph.Radius `json:"blast_radius,omitempty"`
 }
 
 var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
 
 var (
 	fileLineRegex  = regexp.MustCompile(`([a-zA-Z0-9_./-]+\.(ts|js|go|py|java|rs)):(\d+)`)
+	pyFrameRegex   = regexp.MustCompile(`File "([^"]+\.py)", line \d+`)
 	errorLineHints = []string{
 		"error", "fail", "exception", "panic",
 		"syntaxerror", "typeerror", "referenceerror",
@@ -55,23 +86,38 @@ var (
 )
 
 func FetchWorkflowFailures(
+	ctx context.Context,
 	client *github.Client,
 	owner,
 	repo string,
 ) ([]WorkflowCrash, error) {
+	return FetchWorkflowFailuresIn(ctx, client, owner, repo, DefaultWindow())
+}
 
-	ctx := context.Background()
-	cutoff := time.Now().AddDate(0, -3, 0)
-	maxFailures := 20
+// FetchWorkflowFailuresIn collects up to MaxWorkflowFailures failed runs
+// created within w.
+func FetchWorkflowFailuresIn(
+	ctx context.Context,
+	client *github.Client,
+	owner,
+	repo string,
+	w Window,
+) ([]WorkflowCrash, error) {
 
 	log.Printf("[ingest] fetching workflow crashes for %s/%s", owner, repo)
 
+	var defaultBranch string
+	if r, _, err := client.Repositories.Get(ctx, owner, repo); err == nil {
+		defaultBranch = r.GetDefaultBranch()
+	}
+
 	runs, _, err := client.Actions.ListRepositoryWorkflowRuns(
 		ctx,
 		owner,
 		repo,
 		&github.ListWorkflowRunsOptions{
 			Status:      "failure",
+			Created:     w.searchRange(),
 			ListOptions: github.ListOptions{PerPage: 40},
 		},
 	)
@@ -82,7 +128,10 @@ func FetchWorkflowFailures(
 	var out []WorkflowCrash
 
 	for _, run := range runs.WorkflowRuns {
-		if len(out) >= maxFailures || run.GetCreatedAt().Before(cutoff) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if len(out) >= MaxWorkflowFailures || !w.Contains(run.GetCreatedAt().Time) {
 			continue
 		}
 
@@ -119,7 +168,10 @@ func FetchWorkflowFailures(
 			continue
 		}
 
-		rawLog, _ := downloadLogContent(logURL.String())
+		rawLog, truncated, err := downloadLogContent(ctx, logURL.String(), LogLimits)
+		if err != nil {
+			log.Printf("[ingest] log download failed for job %d: %v", failedJob.GetID(), err)
+		}
 		clean := cleanANSI(rawLog)
 
 		sig, files, lines := extractErrorContext(clean)
@@ -131,6 +183,7 @@ func FetchWorkflowFailures(
 			ErrorSignature: sig,
 			ErrorFiles:     files,
 			ErrorLines:     lines,
+			LogTruncated:   truncated,
 			HTMLURL:        run.GetHTMLURL(),
 			CreatedAt:      run.GetCreatedAt().Time,
 			Branch:         run.GetHeadBranch(),
@@ -188,10 +241,7 @@ func FetchWorkflowFailures(
 					if f.GetPatch() == "" {
 						continue
 					}
-					changes = append(changes, CodeChange{
-						Filename: f.GetFilename(),
-						Patch:    f.GetPatch(),
-					})
+					changes = append(changes, newCodeChange(f.GetFilename(), f.GetPatch()))
 				}
 
 				if resp.NextPage == 0 {
@@ -216,10 +266,7 @@ func FetchWorkflowFailures(
 				if f.GetPatch() == "" {
 					continue
 				}
-				changes = append(changes, CodeChange{
-					Filename: f.GetFilename(),
-					Patch:    f.GetPatch(),
-				})
+				changes = append(changes, newCodeChange(f.GetFilename(), f.GetPatch()))
 			}
 
 			crash.Change = ChangeContext{
@@ -230,10 +277,7 @@ func FetchWorkflowFailures(
 		} else if commit != nil {
 
 			for _, f := range commit.Files {
-				changes = append(changes, CodeChange{
-					Filename: f.GetFilename(),
-					Patch:    f.GetPatch(),
-				})
+				changes = append(changes, newCodeChange(f.GetFilename(), f.GetPatch()))
 			}
 
 			crash.Change = ChangeContext{
@@ -243,46 +287,34 @@ func FetchWorkflowFailures(
 			}
 		}
 
-		out = append(out, crash)
-	}
-
-	_ = writeJSON("crashes.json", out)
-	return out, nil
-}
-
-func downloadLogContent(url string) (string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+		if defaultBranch != "" && crash.Branch == defaultBranch {
+			lastGreen, culprits, skipped, err := findCulprits(ctx, client, owner, repo, run, files)
+			if err != nil {
+				log.Printf("[ingest] culprit search failed for run %d: %v", run.GetID(), err)
+			}
+			if skipped > 0 {
+				log.Printf("[ingest] run %d: %d older commit(s) since last green not ranked", run.GetID(), skipped)
+			}
+			crash.LastGreenSHA = lastGreen
+			crash.Culprits = culprits
+			crash.CulpritsSkipped = skipped
 
-	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
-	if err == nil {
-		var out []string
-		for _, f := range zr.File {
-			rc, _ := f.Open()
-			b, _ := io.ReadAll(rc)
-			rc.Close()
-			out = append(out, tailLines(string(b), 50))
+			if len(culprits) > 0 && culprits[0].Score > 0 && culprits[0].SHA != crash.HeadSHA {
+				crash.Change = ChangeContext{
+					Type:   "culprit",
+					Branch: run.GetHeadBranch(),
+					Files:  culprits[0].changes,
+				}
+			}
 		}
-		return strings.Join(out, "\n---\n"), nil
-	}
 
-	return tailLines(string(body), 50), nil
-}
+		crash.Keywords = keywords.Extract(crashText(&crash), keywordLimit)
 
-func tailLines(s string, n int) string {
-	lines := strings.Split(strings.TrimSpace(s), "\n")
-	if len(lines) <= n {
-		return s
+		out = append(out, crash)
 	}
-	return strings.Join(lines[len(lines)-n:], "\n")
+
+	_ = writeJSON("crashes.json", out)
+	return out, nil
 }
 
 func cleanANSI(s string) string {
@@ -309,6 +341,9 @@ func extractErrorContext(log string) (string, []string, []string) {
 		for _, m := range matches {
 			files[m[1]] = true
 		}
+		for _, m := range pyFrameRegex.FindAllStringSubmatch(l, -1) {
+			files[m[1]] = true
+		}
 	}
 
 	if len(errorLines) > 12 {
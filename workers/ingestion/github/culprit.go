@@ -0,0 +1,136 @@
+package github
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const maxCulpritCommits = 15
+
+type CulpritCommit struct {
+	SHA          string    `json:"sha"`
+	Message      string    `json:"message"`
+	Author       string    `json:"author"`
+	CommittedAt  time.Time `json:"committed_at"`
+	Files        []string  `json:"files"`
+	MatchedFiles []string  `json:"matched_files,omitempty"`
+	Score        float32   `json:"score"`
+	Rank         int       `json:"rank"`
+
+	changes []CodeChange
+}
+
+// findCulprits looks up the last successful run of the same workflow on the
+// run's branch and ranks the commits between that run and the failing one by
+// how much their changed files overlap with the files seen in the failure.
+// Only the newest maxCulpritCommits are ranked; skipped counts the older
+// commits in the range that were not looked at.
+func findCulprits(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	run *github.WorkflowRun,
+	errorFiles []string,
+) (lastGreen string, culprits []CulpritCommit, skipped int, err error) {
+
+	green, _, err := client.Actions.ListWorkflowRunsByID(
+		ctx,
+		owner,
+		repo,
+		run.GetWorkflowID(),
+		&github.ListWorkflowRunsOptions{
+			Branch:      run.GetHeadBranch(),
+			Status:      "success",
+			Created:     "<" + run.GetCreatedAt().UTC().Format(time.RFC3339),
+			ListOptions: github.ListOptions{PerPage: 1},
+		},
+	)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if len(green.WorkflowRuns) == 0 {
+		return "", nil, 0, nil
+	}
+
+	lastGreen = green.WorkflowRuns[0].GetHeadSHA()
+	if lastGreen == "" || lastGreen == run.GetHeadSHA() {
+		return lastGreen, nil, 0, nil
+	}
+
+	commits, total, err := compareTail(ctx, client, owner, repo, lastGreen, run.GetHeadSHA(), maxCulpritCommits)
+	if err != nil {
+		return lastGreen, nil, 0, err
+	}
+	skipped = total - len(commits)
+
+	out := make([]CulpritCommit, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+
+		full, _, err := client.Repositories.GetCommit(ctx, owner, repo, c.GetSHA(), nil)
+		if err != nil {
+			continue
+		}
+
+		cc := CulpritCommit{
+			SHA:         c.GetSHA(),
+			Message:     c.GetCommit().GetMessage(),
+			Author:      c.GetCommit().GetAuthor().GetName(),
+			CommittedAt: c.GetCommit().GetCommitter().GetDate().Time,
+		}
+		if login := c.GetAuthor().GetLogin(); login != "" {
+			cc.Author = login
+		}
+
+		for _, f := range full.Files {
+			cc.Files = append(cc.Files, f.GetFilename())
+			if f.GetPatch() != "" {
+				cc.changes = append(cc.changes, newCodeChange(f.GetFilename(), f.GetPatch()))
+			}
+
+			if w := fileMatchWeight(f.GetFilename(), errorFiles); w > 0 {
+				cc.Score += w
+				cc.MatchedFiles = append(cc.MatchedFiles, f.GetFilename())
+			}
+		}
+
+		out = append(out, cc)
+	}
+
+	// out is newest first, so a stable sort keeps recency as the tie-break.
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	for i := range out {
+		out[i].Rank = i + 1
+	}
+
+	return lastGreen, out, skipped, nil
+}
+
+// fileMatchWeight scores how well a repo path matches any path parsed from a
+// log. Log paths are often absolute runner paths or relative to a package, so
+// suffix matches on a path count fully and bare file-name matches count half.
+func fileMatchWeight(repoPath string, errorFiles []string) float32 {
+	repoPath = strings.TrimPrefix(repoPath, "./")
+	base := path.Base(repoPath)
+
+	var best float32
+	for _, ef := range errorFiles {
+		ef = strings.TrimPrefix(ef, "./")
+		switch {
+		case ef == repoPath && strings.Contains(ef, "/"),
+			strings.HasSuffix(ef, "/"+repoPath),
+			strings.Contains(ef, "/") && strings.HasSuffix(repoPath, "/"+ef):
+			return 1.0
+		case path.Base(ef) == base:
+			best = 0.5
+		}
+	}
+	return best
+}
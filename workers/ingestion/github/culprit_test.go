@@ -0,0 +1,26 @@
+package github
+
+import "testing"
+
+func TestFileMatchWeight(t *testing.T) {
+	tests := []struct {
+		repoPath string
+		errFiles []string
+		want     float32
+	}{
+		{"pkg/api/server.go", []string{"pkg/api/server.go"}, 1.0},
+		{"pkg/api/server.go", []string{"/home/runner/work/app/app/pkg/api/server.go"}, 1.0},
+		{"pkg/api/server.go", []string{"api/server.go"}, 1.0},
+		{"pkg/api/server.go", []string{"./pkg/api/server.go"}, 1.0},
+		{"pkg/api/server.go", []string{"server.go"}, 0.5},
+		{"main.go", []string{"main.go"}, 0.5},
+		{"pkg/api/server.go", []string{"cmd/other/server.go"}, 0.5},
+		{"pkg/api/server.go", []string{"client.go"}, 0},
+		{"pkg/api/server.go", []string{"server.go", "pkg/api/server.go"}, 1.0},
+	}
+	for _, tt := range tests {
+		if got := fileMatchWeight(tt.repoPath, tt.errFiles); got != tt.want {
+			t.Errorf("fileMatchWeight(%q, %q) = %v, want %v", tt.repoPath, tt.errFiles, got, tt.want)
+		}
+	}
+}
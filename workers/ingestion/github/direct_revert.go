@@ -0,0 +1,250 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+
+	sharediff "codrel-sentinel/workers/shared/diff"
+)
+
+const (
+	directRevertCommitPages = 5
+	directRevertEventPages  = 3
+	zeroSHA                 = "0000000000000000000000000000000000000000"
+)
+
+// FetchDirectReverts finds reverts that landed on the default branch without
+// going through a PR: revert commits pushed straight to the branch and
+// force-pushes that rolled the branch back. Each one is returned in the same
+// shape as a reverted PR so it can flow through RevertedPRPayload.
+func FetchDirectReverts(
+	ctx context.Context,
+	client *github.Client,
+	owner string,
+	repo string,
+) ([]MinimalPR, error) {
+
+	cutoff := WindowStart()
+
+	log.Printf("[ingest] scanning default branch for direct reverts in %s/%s", owner, repo)
+
+	r, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	branch := r.GetDefaultBranch()
+
+	out := []MinimalPR{}
+
+	commits, err := fetchDirectRevertCommits(ctx, client, owner, repo, branch, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, commits...)
+
+	rollbacks, err := fetchForcePushRollbacks(ctx, client, owner, repo, branch, cutoff)
+	if err != nil {
+		log.Printf("[ingest] force-push scan failed for %s/%s: %v", owner, repo, err)
+	}
+	out = append(out, rollbacks...)
+
+	log.Printf("[ingest] direct reverts | commits=%d rollbacks=%d", len(commits), len(rollbacks))
+
+	_ = writeJSON("direct_reverts.json", out)
+	return out, nil
+}
+
+func isRevertCommitMessage(msg string) bool {
+	return strings.HasPrefix(strings.ToLower(msg), "revert \"") ||
+		revertsCommitRegex.MatchString(msg)
+}
+
+func fetchDirectRevertCommits(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo, branch string,
+	cutoff time.Time,
+) ([]MinimalPR, error) {
+
+	var out []MinimalPR
+	opt := &github.CommitsListOptions{
+		SHA:         branch,
+		Since:       cutoff,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for page := 0; page < directRevertCommitPages; page++ {
+		commits, resp, err := client.Repositories.ListCommits(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range commits {
+			msg := c.GetCommit().GetMessage()
+			if !isRevertCommitMessage(msg) {
+				continue
+			}
+
+			// Reverts merged through a PR are picked up by FetchClosedPRBuckets.
+			if findMergedPRForSHAs(ctx, client, owner, repo, []string{c.GetSHA()}, 0) != 0 {
+				continue
+			}
+
+			committedAt := c.GetCommit().GetCommitter().GetDate().Time
+
+			diff, _, err := client.Repositories.GetCommitRaw(
+				ctx,
+				owner,
+				repo,
+				c.GetSHA(),
+				github.RawOptions{Type: github.Diff},
+			)
+			if err != nil {
+				log.Printf("[ingest] revert commit diff failed for %s: %v", c.GetSHA(), err)
+			}
+
+			base := MinimalPR{
+				Title:            strings.SplitN(msg, "\n", 2)[0],
+				Body:             msg,
+				CreatedAt:        committedAt,
+				MergedAt:         &committedAt,
+				MergeCommitSHA:   c.GetSHA(),
+				HTMLURL:          c.GetHTMLURL(),
+				Diff:             diff,
+				DiffFiles:        sharediff.SummarizeAll(diff),
+				BaseBranch:       branch,
+				RevertKind:       "direct_commit",
+				RevertConfidence: 1.0,
+			}
+			if len(c.Parents) > 0 {
+				base.Symbols = goSymbolChanges(ctx, client, owner, repo, c.Parents[0].GetSHA(), c.GetSHA(), base.DiffFiles)
+			}
+
+			shas := parseRevertedSHAs(msg)
+			number := parseRevertedPR(owner, repo, msg)
+			if number == 0 {
+				number = findMergedPRForSHAs(ctx, client, owner, repo, shas, 0)
+			}
+			base.applyRevertTarget(loadRevertTarget(ctx, client, owner, repo, shas, number, committedAt))
+
+			out = append(out, base)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return out, nil
+}
+
+// fetchForcePushRollbacks inspects recent push events on the branch. A push
+// whose previous head is not contained in the new head dropped commits; if the
+// new head is an ancestor of the old one the branch was rolled back outright.
+func fetchForcePushRollbacks(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo, branch string,
+	cutoff time.Time,
+) ([]MinimalPR, error) {
+
+	var out []MinimalPR
+	ref := "refs/heads/" + branch
+	opt := &github.ListOptions{PerPage: 100}
+
+	for page := 0; page < directRevertEventPages; page++ {
+		events, resp, err := client.Activity.ListRepositoryEvents(ctx, owner, repo, opt)
+		if err != nil {
+			return out, err
+		}
+
+		for _, e := range events {
+			if e.GetType() != "PushEvent" || e.GetCreatedAt().Before(cutoff) {
+				continue
+			}
+
+			payload, err := e.ParsePayload()
+			if err != nil {
+				continue
+			}
+			push, ok := payload.(*github.PushEvent)
+			if !ok || push.GetRef() != ref {
+				continue
+			}
+
+			before, head := push.GetBefore(), push.GetHead()
+			if before == "" || head == "" || before == zeroSHA || before == head {
+				continue
+			}
+
+			cmp, _, err := client.Repositories.CompareCommits(ctx, owner, repo, head, before, &github.ListOptions{PerPage: 100})
+			if err != nil {
+				continue
+			}
+
+			var confidence float32
+			switch cmp.GetStatus() {
+			case "ahead":
+				confidence = 1.0
+			case "diverged":
+				confidence = 0.7
+			default:
+				continue
+			}
+
+			var (
+				dropped []string
+				body    strings.Builder
+			)
+			for _, c := range cmp.Commits {
+				dropped = append(dropped, c.GetSHA())
+				body.WriteString(strings.SplitN(c.GetCommit().GetMessage(), "\n", 2)[0])
+				body.WriteString("\n")
+			}
+
+			diff, _, err := client.Repositories.CompareCommitsRaw(
+				ctx,
+				owner,
+				repo,
+				head,
+				before,
+				github.RawOptions{Type: github.Diff},
+			)
+			if err != nil {
+				log.Printf("[ingest] rollback diff failed for %s..%s: %v", head, before, err)
+			}
+
+			pushedAt := e.GetCreatedAt().Time
+			files := sharediff.SummarizeAll(diff)
+
+			out = append(out, MinimalPR{
+				Title:             fmt.Sprintf("Force-push rollback of %d commit(s) on %s", len(dropped), branch),
+				Body:              strings.TrimSpace(body.String()),
+				CreatedAt:         pushedAt,
+				MergedAt:          &pushedAt,
+				MergeCommitSHA:    head,
+				HTMLURL:           cmp.GetHTMLURL(),
+				BaseBranch:        branch,
+				RevertKind:        "force_push",
+				RevertConfidence:  confidence,
+				RevertedSHAs:      dropped,
+				OriginalDiff:      diff,
+				OriginalDiffFiles: files,
+				OriginalSymbols:   goSymbolChanges(ctx, client, owner, repo, head, before, files),
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	historyPages = 5
+
+	// MaxHistoryCommits caps the commits loaded per job.
+	MaxHistoryCommits = 300
+)
+
+// FileChurn is one file's share of a commit.
+type FileChurn struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// HistoryCommit is a non-merge commit on the default branch with its files.
+type HistoryCommit struct {
+	SHA         string      `json:"sha"`
+	Author      string      `json:"author"`
+	CommittedAt time.Time   `json:"committed_at"`
+	Files       []FileChurn `json:"files"`
+}
+
+// FetchCommitHistory walks the default branch over the ingestion window,
+// newest first, and loads the changed files of each non-merge commit. The
+// list endpoint does not return files, so each commit costs one extra call;
+// MaxHistoryCommits bounds that.
+func FetchCommitHistory(
+	ctx context.Context,
+	client *github.Client,
+	owner string,
+	repo string,
+) ([]HistoryCommit, error) {
+
+	cutoff := WindowStart()
+
+	log.Printf("[ingest] fetching commit history for %s/%s", owner, repo)
+
+	r, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &github.CommitsListOptions{
+		SHA:         r.GetDefaultBranch(),
+		Since:       cutoff,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var out []HistoryCommit
+
+pages:
+	for page := 0; page < historyPages; page++ {
+		commits, resp, err := client.Repositories.ListCommits(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range commits {
+			if len(c.Parents) > 1 {
+				continue
+			}
+			if len(out) >= MaxHistoryCommits {
+				break pages
+			}
+
+			full, _, err := client.Repositories.GetCommit(ctx, owner, repo, c.GetSHA(), &github.ListOptions{PerPage: 100})
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("[ingest] commit %s failed: %v", shortSHA(c.GetSHA()), err)
+				continue
+			}
+
+			hc := HistoryCommit{
+				SHA:         c.GetSHA(),
+				Author:      commitAuthor(c),
+				CommittedAt: c.GetCommit().GetCommitter().GetDate().Time,
+			}
+			for _, f := range full.Files {
+				hc.Files = append(hc.Files, FileChurn{
+					Path:      f.GetFilename(),
+					Additions: f.GetAdditions(),
+					Deletions: f.GetDeletions(),
+				})
+			}
+			out = append(out, hc)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	log.Printf("[ingest] commit history | commits=%d", len(out))
+	return out, nil
+}
+
+// commitAuthor prefers the GitHub login and falls back to the git author
+// email, then name, so unlinked authors are still told apart.
+func commitAuthor(c *github.RepositoryCommit) string {
+	if login := c.GetAuthor().GetLogin(); login != "" {
+		return login
+	}
+	if email := c.GetCommit().GetAuthor().GetEmail(); email != "" {
+		return email
+	}
+	return c.GetCommit().GetAuthor().GetName()
+}
@@ -0,0 +1,151 @@
+package github
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	maxHotspots        = 200
+	recencyHalfLifeDay = 30.0
+)
+
+// FileHotspot summarises how much and by whom a file changed in the window.
+// BusFactor is the fewest authors who together wrote more than half of the
+// file's churn.
+type FileHotspot struct {
+	Path         string    `json:"path"`
+	Commits      int       `json:"commits"`
+	Additions    int       `json:"additions"`
+	Deletions    int       `json:"deletions"`
+	Churn        int       `json:"churn"`
+	Authors      int       `json:"authors"`
+	BusFactor    int       `json:"bus_factor"`
+	TopAuthor    string    `json:"top_author"`
+	LastChanged  time.Time `json:"last_changed"`
+	DaysSince    float64   `json:"days_since_change"`
+	Recency      float64   `json:"recency"`
+	HotspotScore float64   `json:"hotspot_score"`
+}
+
+// ComputeHotspots aggregates per-file churn from the commit history and
+// returns the top files by hotspot score. The score blends commit frequency
+// and log churn, each relative to the busiest file, and decays with the time
+// since the file last changed.
+func ComputeHotspots(history []HistoryCommit) []FileHotspot {
+	if len(history) == 0 {
+		return nil
+	}
+
+	type acc struct {
+		hotspot FileHotspot
+		byUser  map[string]int
+	}
+
+	files := map[string]*acc{}
+	for _, c := range history {
+		for _, f := range c.Files {
+			a, ok := files[f.Path]
+			if !ok {
+				a = &acc{hotspot: FileHotspot{Path: f.Path}, byUser: map[string]int{}}
+				files[f.Path] = a
+			}
+			h := &a.hotspot
+			h.Commits++
+			h.Additions += f.Additions
+			h.Deletions += f.Deletions
+			if c.CommittedAt.After(h.LastChanged) {
+				h.LastChanged = c.CommittedAt
+			}
+
+			// Binary and rename-only changes have no line counts; count
+			// them as one line so the author still gets credit.
+			lines := f.Additions + f.Deletions
+			if lines == 0 {
+				lines = 1
+			}
+			a.byUser[c.Author] += lines
+		}
+	}
+
+	now := time.Now()
+	maxCommits, maxChurn := 0, 0
+	out := make([]FileHotspot, 0, len(files))
+
+	for _, a := range files {
+		h := a.hotspot
+		h.Churn = h.Additions + h.Deletions
+		h.Authors = len(a.byUser)
+		h.BusFactor, h.TopAuthor = busFactor(a.byUser)
+		h.DaysSince = round2(now.Sub(h.LastChanged).Hours() / 24)
+		h.Recency = round2(math.Exp(-math.Ln2 * h.DaysSince / recencyHalfLifeDay))
+
+		if h.Commits > maxCommits {
+			maxCommits = h.Commits
+		}
+		if h.Churn > maxChurn {
+			maxChurn = h.Churn
+		}
+		out = append(out, h)
+	}
+
+	for i := range out {
+		h := &out[i]
+		frequency := float64(h.Commits) / float64(maxCommits)
+		churn := 0.0
+		if maxChurn > 0 {
+			churn = math.Log1p(float64(h.Churn)) / math.Log1p(float64(maxChurn))
+		}
+		h.HotspotScore = round2((0.5*frequency + 0.5*churn) * (0.5 + 0.5*h.Recency))
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].HotspotScore != out[j].HotspotScore {
+			return out[i].HotspotScore > out[j].HotspotScore
+		}
+		return out[i].Path < out[j].Path
+	})
+	if len(out) > maxHotspots {
+		out = out[:maxHotspots]
+	}
+
+	_ = writeJSON("hotspots.json", out)
+	return out
+}
+
+func busFactor(byUser map[string]int) (int, string) {
+	type share struct {
+		author string
+		lines  int
+	}
+
+	total := 0
+	shares := make([]share, 0, len(byUser))
+	for u, n := range byUser {
+		shares = append(shares, share{u, n})
+		total += n
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].lines != shares[j].lines {
+			return shares[i].lines > shares[j].lines
+		}
+		return shares[i].author < shares[j].author
+	})
+	if len(shares) == 0 {
+		return 0, ""
+	}
+
+	covered := 0
+	for i, s := range shares {
+		covered += s.lines
+		if covered*2 > total {
+			return i + 1, shares[0].author
+		}
+	}
+	return len(shares), shares[0].author
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,66 @@
+package github
+
+import (
+	"strings"
+
+	"codrel-sentinel/workers/ingestion-worker/keywords"
+)
+
+const keywordLimit = 8
+
+func issueText(i *Issue) string {
+	return i.Title + "\n" + i.Body
+}
+
+func crashText(c *WorkflowCrash) string {
+	return strings.Join([]string{
+		c.Name,
+		c.JobName,
+		c.ErrorSignature,
+		strings.Join(c.ErrorFiles, " "),
+		c.CommitMsg,
+	}, "\n")
+}
+
+func prText(p *MinimalPR) string {
+	return p.Title + "\n" + p.Body
+}
+
+// AnnotateKeywords re-ranks the keywords of every issue, crash and PR from one
+// ingestion job by TF-IDF against a corpus built from all of them plus the
+// counts stored from the repo's earlier jobs, so terms that appear everywhere
+// in the repo give way to distinctive ones. It returns the counts of this
+// job's items alone, for the caller to store.
+func AnnotateKeywords(past []keywords.Counts, issues []Issue, crashes []WorkflowCrash, prs ...[]MinimalPR) keywords.Counts {
+	corpus := keywords.NewCorpus()
+
+	for i := range issues {
+		corpus.Add(issueText(&issues[i]))
+	}
+	for i := range crashes {
+		corpus.Add(crashText(&crashes[i]))
+	}
+	for _, bucket := range prs {
+		for i := range bucket {
+			corpus.Add(prText(&bucket[i]))
+		}
+	}
+
+	counts := corpus.Counts(keywords.MaxStoredTerms)
+	for _, c := range past {
+		corpus.Merge(c)
+	}
+
+	for i := range issues {
+		issues[i].Keywords = corpus.Top(issueText(&issues[i]), keywordLimit)
+	}
+	for i := range crashes {
+		crashes[i].Keywords = corpus.Top(crashText(&crashes[i]), keywordLimit)
+	}
+	for _, bucket := range prs {
+		for i := range bucket {
+			bucket[i].Keywords = corpus.Top(prText(&bucket[i]), keywordLimit)
+		}
+	}
+	return counts
+}
@@ -0,0 +1,348 @@
+package github
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// LogOptions bounds how much of a workflow log is downloaded, read and kept.
+type LogOptions struct {
+	Timeout         time.Duration `json:"timeout"`
+	MaxArchiveBytes int64         `json:"max_archive_bytes"`
+	MaxEntryBytes   int64         `json:"max_entry_bytes"`
+	MaxLineBytes    int           `json:"max_line_bytes"`
+
+	ContextBefore   int `json:"context_before"`
+	ContextAfter    int `json:"context_after"`
+	MaxExcerptLines int `json:"max_excerpt_lines"`
+	FallbackTail    int `json:"fallback_tail"`
+}
+
+func DefaultLogOptions() LogOptions {
+	return LogOptions{
+		Timeout:         60 * time.Second,
+		MaxArchiveBytes: 64 << 20,
+		MaxEntryBytes:   16 << 20,
+		MaxLineBytes:    4096,
+		ContextBefore:   3,
+		ContextAfter:    5,
+		MaxExcerptLines: 120,
+		FallbackTail:    50,
+	}
+}
+
+// LogLimits is used by FetchWorkflowFailures for every job log it downloads.
+var LogLimits = DefaultLogOptions()
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	zipDescriptor = []byte("PK\x07\x08")
+)
+
+// downloadLogContent downloads a job log and returns its excerpt. truncated
+// reports that part of the log was past MaxArchiveBytes or MaxEntryBytes, so
+// the excerpt covers only what came before.
+func downloadLogContent(ctx context.Context, url string, opts LogOptions) (excerpt string, truncated bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("log download failed: HTTP %d", resp.StatusCode)
+	}
+
+	tmp, err := os.CreateTemp("", "sentinel-log-*")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, opts.MaxArchiveBytes+1))
+	if err != nil {
+		return "", false, err
+	}
+	truncated = n > opts.MaxArchiveBytes
+	if truncated {
+		n = opts.MaxArchiveBytes
+	}
+
+	head := make([]byte, len(zipMagic))
+	if _, err := tmp.ReadAt(head, 0); err == nil && bytes.Equal(head, zipMagic) {
+		if truncated {
+			return readTruncatedArchive(io.NewSectionReader(tmp, 0, n), opts), true, nil
+		}
+		return readLogArchive(tmp, n, opts)
+	}
+
+	excerpt, err = scanLogExcerpt(io.NewSectionReader(tmp, 0, n), opts)
+	return excerpt, truncated, err
+}
+
+func readLogArchive(r io.ReaderAt, size int64, opts LogOptions) (string, bool, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", false, err
+	}
+
+	var out []string
+	truncated := false
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(opts.MaxEntryBytes) {
+			truncated = true
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", false, fmt.Errorf("open %s: %w", f.Name, err)
+		}
+		excerpt, err := scanLogExcerpt(io.LimitReader(rc, opts.MaxEntryBytes), opts)
+		rc.Close()
+		if err != nil {
+			return "", false, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		if excerpt != "" {
+			out = append(out, excerpt)
+		}
+	}
+
+	return strings.Join(out, "\n---\n"), truncated, nil
+}
+
+// readTruncatedArchive reads a zip cut off at the size limit. Its central
+// directory is gone, so entries are walked by their local headers in order
+// until the data runs out; the entry that was cut short still yields an
+// excerpt of what it has.
+func readTruncatedArchive(r io.Reader, opts LogOptions) string {
+	br := bufio.NewReader(r)
+
+	var out []string
+	for {
+		var hdr [30]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil || !bytes.Equal(hdr[:4], zipMagic) {
+			break
+		}
+		flags := binary.LittleEndian.Uint16(hdr[6:])
+		method := binary.LittleEndian.Uint16(hdr[8:])
+		size := int64(binary.LittleEndian.Uint32(hdr[18:]))
+		skip := int(binary.LittleEndian.Uint16(hdr[26:])) + int(binary.LittleEndian.Uint16(hdr[28:]))
+		if _, err := br.Discard(skip); err != nil {
+			break
+		}
+		described := flags&0x8 != 0
+
+		var body io.Reader
+		switch {
+		case method == zip.Deflate:
+			// flate stops at the end of the stream, so the sizes are not needed.
+			body = flate.NewReader(br)
+		case method == zip.Store && !described:
+			body = io.LimitReader(br, size)
+		default:
+			return strings.Join(out, "\n---\n")
+		}
+		body = cutReader{body}
+
+		excerpt, err := scanLogExcerpt(io.LimitReader(body, opts.MaxEntryBytes), opts)
+		if excerpt != "" {
+			out = append(out, excerpt)
+		}
+		if err != nil {
+			break
+		}
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			break
+		}
+
+		if described {
+			if sig, err := br.Peek(len(zipDescriptor)); err == nil && bytes.Equal(sig, zipDescriptor) {
+				br.Discard(len(zipDescriptor))
+			}
+			br.Discard(12)
+		}
+	}
+
+	return strings.Join(out, "\n---\n")
+}
+
+// cutReader ends a stream that was cut short with io.EOF, so what was read
+// before the cut is scanned like a complete log.
+type cutReader struct {
+	r io.Reader
+}
+
+func (c cutReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// scanLogExcerpt reads r line by line and keeps a window of lines around
+// every error marker. If no marker is found the last FallbackTail lines are
+// returned instead. Memory use is bounded by the window sizes and MaxLineBytes.
+func scanLogExcerpt(r io.Reader, opts LogOptions) (string, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	before := newLineRing(opts.ContextBefore)
+	tail := newLineRing(opts.FallbackTail)
+
+	var (
+		excerpt   []string
+		after     int
+		lineNo    int
+		lastEmit  = -1
+		matched   bool
+		exhausted bool
+	)
+
+	emit := func(no int, line string) {
+		if len(excerpt) >= opts.MaxExcerptLines {
+			exhausted = true
+			return
+		}
+		if lastEmit >= 0 && no > lastEmit+1 {
+			excerpt = append(excerpt, "...")
+		}
+		excerpt = append(excerpt, line)
+		lastEmit = no
+	}
+
+	for {
+		line, err := readBoundedLine(br, opts.MaxLineBytes)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+
+		lineNo++
+		line = cleanANSI(strings.TrimRight(line, "\r"))
+		tail.push(lineNo, line)
+
+		if !exhausted {
+			switch {
+			case isErrorMarker(line):
+				matched = true
+				for _, l := range before.drain() {
+					emit(l.no, l.text)
+				}
+				emit(lineNo, line)
+				after = opts.ContextAfter
+			case after > 0:
+				emit(lineNo, line)
+				after--
+			default:
+				before.push(lineNo, line)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if !matched {
+		lines := make([]string, 0, opts.FallbackTail)
+		for _, l := range tail.drain() {
+			lines = append(lines, l.text)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	return strings.Join(excerpt, "\n"), nil
+}
+
+// readBoundedLine returns the next line without its newline, keeping at most
+// max bytes and discarding the remainder of overlong lines.
+func readBoundedLine(br *bufio.Reader, max int) (string, error) {
+	var buf []byte
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return string(buf), err
+		}
+		if room := max - len(buf); room > 0 {
+			if len(chunk) > room {
+				chunk = chunk[:room]
+			}
+			buf = append(buf, chunk...)
+		}
+		if !isPrefix {
+			return string(buf), nil
+		}
+	}
+}
+
+func isErrorMarker(line string) bool {
+	low := strings.ToLower(line)
+	for _, hint := range errorLineHints {
+		if strings.Contains(low, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+type ringLine struct {
+	no   int
+	text string
+}
+
+type lineRing struct {
+	buf   []ringLine
+	start int
+	size  int
+}
+
+func newLineRing(n int) *lineRing {
+	if n < 0 {
+		n = 0
+	}
+	return &lineRing{buf: make([]ringLine, n)}
+}
+
+func (r *lineRing) push(no int, text string) {
+	if len(r.buf) == 0 {
+		return
+	}
+	idx := (r.start + r.size) % len(r.buf)
+	r.buf[idx] = ringLine{no: no, text: text}
+	if r.size < len(r.buf) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.buf)
+	}
+}
+
+func (r *lineRing) drain() []ringLine {
+	out := make([]ringLine, 0, r.size)
+	for i := 0; i < r.size; i++ {
+		out = append(out, r.buf[(r.start+i)%len(r.buf)])
+	}
+	r.start, r.size = 0, 0
+	return out
+}
@@ -0,0 +1,133 @@
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testLogOptions() LogOptions {
+	opts := DefaultLogOptions()
+	opts.MaxArchiveBytes = 1 << 20
+	return opts
+}
+
+func logArchive(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, body := range entries {
+		w, err := zw.Create(fmt.Sprintf("%d_job.txt", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// noisyLog is a log whose bulk does not compress away, so an archive of it
+// is large enough to cut.
+func noisyLog(marker string, lines int) string {
+	var b strings.Builder
+	b.WriteString("step started\n" + marker + "\n")
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&b, "line %d %x\n", i, uint64(i)*0x9e3779b97f4a7c15)
+	}
+	return b.String()
+}
+
+func serveLog(t *testing.T, body []byte) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestDownloadLogContent(t *testing.T) {
+	first := noisyLog("Error: first job failed", 20000)
+	second := noisyLog("Error: second job failed", 20000)
+	archive := logArchive(t, first, second)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		limit     int64
+		want      []string
+		missing   []string
+		truncated bool
+	}{
+		{
+			name: "plain text",
+			body: []byte("ok\nError: boom\nafter\n"),
+			want: []string{"Error: boom", "after"},
+		},
+		{
+			name: "whole archive",
+			body: archive,
+			want: []string{"first job failed", "second job failed"},
+		},
+		{
+			name:      "archive cut in the second entry",
+			body:      archive,
+			limit:     int64(len(archive)) * 3 / 4,
+			want:      []string{"first job failed", "second job failed"},
+			truncated: true,
+		},
+		{
+			name:      "archive cut in the first entry",
+			body:      archive,
+			limit:     int64(len(archive)) / 4,
+			want:      []string{"first job failed"},
+			missing:   []string{"second job failed"},
+			truncated: true,
+		},
+		{
+			name:      "plain text cut",
+			body:      []byte(noisyLog("Error: early", 20000) + "Error: late\n"),
+			limit:     4096,
+			want:      []string{"Error: early"},
+			missing:   []string{"Error: late"},
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testLogOptions()
+			if tt.limit > 0 {
+				opts.MaxArchiveBytes = tt.limit
+			}
+
+			got, truncated, err := downloadLogContent(context.Background(), serveLog(t, tt.body), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if truncated != tt.truncated {
+				t.Errorf("truncated = %v, want %v", truncated, tt.truncated)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("excerpt lacks %q:\n%s", w, got)
+				}
+			}
+			for _, m := range tt.missing {
+				if strings.Contains(got, m) {
+					t.Errorf("excerpt has %q past the cut", m)
+				}
+			}
+		})
+	}
+}
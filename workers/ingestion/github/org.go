@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// OrgRepo is one repository an installation can access.
+type OrgRepo struct {
+	Owner         string   `json:"owner"`
+	Name          string   `json:"name"`
+	FullName      string   `json:"full_name"`
+	DefaultBranch string   `json:"default_branch"`
+	Archived      bool     `json:"archived"`
+	Fork          bool     `json:"fork"`
+	Private       bool     `json:"private"`
+	Topics        []string `json:"topics,omitempty"`
+}
+
+// OrgFilter picks which of an installation's repos an org request connects.
+// Archived repos and forks are left out unless asked for. Include and
+// Exclude are globs matched against the repo name; an empty Include keeps
+// every name. Topics keeps only repos with at least one of the listed
+// topics, ExcludeTopics drops repos with any of them.
+type OrgFilter struct {
+	Archived      bool     `json:"archived,omitempty"`
+	Forks         bool     `json:"forks,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Topics        []string `json:"topics,omitempty"`
+	ExcludeTopics []string `json:"exclude_topics,omitempty"`
+}
+
+// Validate rejects malformed globs up front, since Match would otherwise
+// treat them as never matching.
+func (f OrgFilter) Validate() error {
+	for _, p := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad name pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether the filter keeps r and, when it does not, why.
+func (f OrgFilter) Match(r OrgRepo) (bool, string) {
+	name := strings.ToLower(r.Name)
+
+	switch {
+	case r.Archived && !f.Archived:
+		return false, "archived"
+	case r.Fork && !f.Forks:
+		return false, "fork"
+	case len(f.Include) > 0 && !matchAny(f.Include, name):
+		return false, "not included by name"
+	case matchAny(f.Exclude, name):
+		return false, "excluded by name"
+	case len(f.Topics) > 0 && !hasAnyTopic(r.Topics, f.Topics):
+		return false, "missing topic"
+	case hasAnyTopic(r.Topics, f.ExcludeTopics):
+		return false, "excluded by topic"
+	}
+	return true, ""
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTopic(topics, want []string) bool {
+	for _, t := range topics {
+		for _, w := range want {
+			if strings.EqualFold(t, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FetchInstallationRepos lists every repo the installation behind client can
+// access that belongs to owner.
+func FetchInstallationRepos(
+	ctx context.Context,
+	client *github.Client,
+	owner string,
+) ([]OrgRepo, error) {
+
+	log.Printf("[ingest] listing installation repos for %s", owner)
+
+	opt := &github.ListOptions{PerPage: 100}
+	var out []OrgRepo
+
+	for {
+		list, resp, err := client.Apps.ListRepos(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range list.Repositories {
+			if !strings.EqualFold(r.GetOwner().GetLogin(), owner) {
+				continue
+			}
+			out = append(out, OrgRepo{
+				Owner:         r.GetOwner().GetLogin(),
+				Name:          r.GetName(),
+				FullName:      r.GetFullName(),
+				DefaultBranch: r.GetDefaultBranch(),
+				Archived:      r.GetArchived(),
+				Fork:          r.GetFork(),
+				Private:       r.GetPrivate(),
+				Topics:        r.Topics,
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	log.Printf("[ingest] installation repos | owner=%s total=%d", owner, len(out))
+	return out, nil
+}
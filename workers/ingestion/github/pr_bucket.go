@@ -3,19 +3,35 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v61/github"
+
+	"codrel-sentinel/workers/shared/depgraph"
+	sharediff "codrel-sentinel/workers/shared/diff"
+	"codrel-sentinel/workers/shared/symbols"
 )
 
 const ENABLE_COMMENTS = true
 
+// MaxBackfillPRs is the most closed PRs one backfill slice should hold;
+// busier slices are split before they are fetched.
+const MaxBackfillPRs = 200
+
+// maxSearchResults is how far GitHub search pages go for one query.
+const maxSearchResults = 1000
+
 type PRBuckets struct {
 	Reverted []MinimalPR `json:"reverted"`
 	Rejected []MinimalPR `json:"rejected"`
+	Merged   []MinimalPR `json:"merged"`
+
+	// Unlisted counts PRs a bounded search matched but could not page to.
+	Unlisted int `json:"-"`
 }
 
 type RevertSignal struct {
@@ -32,48 +48,79 @@ type MinimalComment struct {
 }
 
 type MinimalPR struct {
-	Number         int              `json:"number"`
-	Title          string           `json:"title"`
-	Body           string           `json:"body"`
-	CreatedAt      time.Time        `json:"created_at"`
-	MergedAt       *time.Time       `json:"merged_at,omitempty"`
-	MergeCommitSHA string           `json:"merge_commit_sha,omitempty"`
-	HTMLURL        string           `json:"html_url"`
-	Diff           string           `json:"diff,omitempty"`
-	Comments       []MinimalComment `json:"comments,omitempty"`
+	Number         int                     `json:"number"`
+	Title          string                  `json:"title"`
+	Body           string                  `json:"body"`
+	CreatedAt      time.Time               `json:"created_at"`
+	MergedAt       *time.Time              `json:"merged_at,omitempty"`
+	MergeCommitSHA string                  `json:"merge_commit_sha,omitempty"`
+	HTMLURL        string                  `json:"html_url"`
+	Diff           string                  `json:"diff,omitempty"`
+	Files          []string                `json:"files,omitempty"`
+	CommitSHAs     []string                `json:"commit_shas,omitempty"`
+	DiffFiles      []sharediff.FileSummary `json:"diff_files,omitempty"`
+	Comments       []MinimalComment        `json:"comments,omitempty"`
 
 	SourceBranch string `json:"source_branch"`
 	BaseBranch   string `json:"base_branch"`
 	
-	RejectionReason string `json:"rejection_reason,omitempty"`
-	Authorship      string `json:"authorship,omitempty"`
+	RejectionReason     string            `json:"rejection_reason,omitempty"`
+	RejectionConfidence float32           `json:"rejection_confidence,omitempty"`
+	RejectionSignals    []RejectionSignal `json:"rejection_signals,omitempty"`
+	Authorship          string            `json:"authorship,omitempty"`
+
+	Review *ReviewStats `json:"review,omitempty"`
+
+	BlastRadius *depgraph.Radius `json:"blast_radius,omitempty"`
+
+	Release            string     `json:"release,omitempty"`
+	ReleaseRolledBack  bool       `json:"release_rolled_back,omitempty"`
+	DeployedAt         *time.Time `json:"deployed_at,omitempty"`
+	DeployEnvironment  string     `json:"deploy_environment,omitempty"`
+	DeployRolledBack   bool       `json:"deploy_rolled_back,omitempty"`
+	MergeToDeployHours float64    `json:"merge_to_deploy_hours,omitempty"`
+
+	Keywords []string `json:"keywords,omitempty"`
 
 	RevertKind       string  `json:"revert_kind,omitempty"`
 	RevertConfidence float32 `json:"revert_confidence,omitempty"`
+
+	RevertedSHAs      []string   `json:"reverted_shas,omitempty"`
+	OriginalPRNumber  int        `json:"original_pr_number,omitempty"`
+	OriginalPRAuthor  string     `json:"original_pr_author,omitempty"`
+	OriginalMergedAt  *time.Time `json:"original_merged_at,omitempty"`
+	TimeToRevertHours float64    `json:"time_to_revert_hours,omitempty"`
+	OriginalDiff      string     `json:"original_diff,omitempty"`
+
+	OriginalDiffFiles []sharediff.FileSummary `json:"original_diff_files,omitempty"`
+
+	Symbols         []symbols.Change `json:"symbols,omitempty"`
+	OriginalSymbols []symbols.Change `json:"original_symbols,omitempty"`
 }
 
 func FetchClosedPRBuckets(
+	ctx context.Context,
 	client *github.Client,
 	owner string,
 	repo string,
 ) (*PRBuckets, error) {
+	return FetchClosedPRBucketsIn(ctx, client, owner, repo, DefaultWindow())
+}
 
-	ctx := context.Background()
-	cutoff := time.Now().AddDate(0, -3, 0)
+// FetchClosedPRBucketsIn buckets the PRs closed within w. Reverts closed
+// before the rolling window are still kept; a bounded window keeps only what
+// closed inside it so adjacent backfill slices do not overlap.
+func FetchClosedPRBucketsIn(
+	ctx context.Context,
+	client *github.Client,
+	owner string,
+	repo string,
+	w Window,
+) (*PRBuckets, error) {
 
 	log.Printf("[ingest] fetching closed PRs for %s/%s", owner, repo)
 
-	prs, _, err := client.PullRequests.List(
-		ctx,
-		owner,
-		repo,
-		&github.PullRequestListOptions{
-			State:       "closed",
-			Sort:        "updated",
-			Direction:   "desc",
-			ListOptions: github.ListOptions{PerPage: 50},
-		},
-	)
+	prs, total, err := listClosedPRs(ctx, client, owner, repo, w)
 	if err != nil {
 		return nil, err
 	}
@@ -81,9 +128,17 @@ func FetchClosedPRBuckets(
 	out := &PRBuckets{
 		Reverted: []MinimalPR{},
 		Rejected: []MinimalPR{},
+		Merged:   []MinimalPR{},
 	}
+	if total > len(prs) {
+		out.Unlisted = total - len(prs)
+	}
+	symbolPRs := 0
 
 	for _, pr := range prs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		title := pr.GetTitle()
 
 		var mergedAt *time.Time
@@ -103,77 +158,60 @@ func FetchClosedPRBuckets(
 			BaseBranch:     pr.GetBase().GetRef(),
 		}
 
-		if ENABLE_COMMENTS {
-			var comments []MinimalComment
+		signal, err := detectRevertSignal(ctx, client, owner, repo, pr)
+if err != nil {
+	return nil, err
+}
 
-			issueComments, _, err := client.Issues.ListComments(
-				ctx,
-				owner,
-				repo,
-				pr.GetNumber(),
-				&github.IssueListCommentsOptions{
-					ListOptions: github.ListOptions{PerPage: 30},
-				},
-			)
-			if err != nil {
-				return nil, err
-			}
+if pr.ClosedAt == nil {
+	continue
+}
 
-			for _, c := range issueComments {
-				u := c.GetUser()
-				isBot := u.GetType() == "Bot"
-
-				comments = append(comments, MinimalComment{
-					Author:     u.GetLogin(),
-					AuthorType: map[bool]string{true: "bot", false: "human"}[isBot],
-					IsBot:      isBot,
-					Body:       c.GetBody(),
-					CreatedAt:  c.GetCreatedAt().Time,
-				})
-			}
+if !w.Contains(pr.ClosedAt.Time) && (signal == nil || w.Bounded()) {
+	continue
+}
 
-			reviewComments, _, err := client.PullRequests.ListComments(
+		// Review comments feed the review stats of merged PRs. Reverted and
+		// rejected PRs carry them with their issue comments instead, so the
+		// final reviewer comments reach the rejection classifier.
+		var reviewComments []*github.PullRequestComment
+		if ENABLE_COMMENTS {
+			reviewComments, _, err = client.PullRequests.ListComments(
 				ctx,
 				owner,
 				repo,
 				pr.GetNumber(),
 				&github.PullRequestListCommentsOptions{
-					ListOptions: github.ListOptions{PerPage: 30},
+					ListOptions: github.ListOptions{PerPage: 100},
 				},
 			)
 			if err != nil {
-				return nil, err
+				log.Printf("[ingest] review comments failed for #%d: %v", pr.GetNumber(), err)
 			}
 
-			for _, c := range reviewComments {
-				u := c.GetUser()
-				isBot := u.GetType() == "Bot"
-
-				comments = append(comments, MinimalComment{
-					Author:     u.GetLogin(),
-					AuthorType: map[bool]string{true: "bot", false: "human"}[isBot],
-					IsBot:      isBot,
-					Body:       c.GetBody(),
-					CreatedAt:  c.GetCreatedAt().Time,
-				})
+			if signal != nil || pr.MergedAt == nil {
+				issueComments, _, err := client.Issues.ListComments(
+					ctx,
+					owner,
+					repo,
+					pr.GetNumber(),
+					&github.IssueListCommentsOptions{
+						ListOptions: github.ListOptions{PerPage: 30},
+					},
+				)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, c := range issueComments {
+					base.Comments = append(base.Comments, minimalComment(c.GetUser(), c.GetBody(), c.GetCreatedAt().Time))
+				}
+				for _, c := range reviewComments {
+					base.Comments = append(base.Comments, minimalComment(c.GetUser(), c.GetBody(), c.GetCreatedAt().Time))
+				}
 			}
-
-			base.Comments = comments
 		}
 
-		signal, err := detectRevertSignal(ctx, client, owner, repo, pr)
-if err != nil {
-	return nil, err
-}
-
-if pr.ClosedAt == nil {
-	continue
-}
-
-if signal == nil && pr.ClosedAt.Before(cutoff) {
-	continue
-}
-
 if signal != nil {
 
 	base.RevertKind = signal.Kind
@@ -191,6 +229,15 @@ if signal != nil {
 			return nil, err
 		}
 		base.Diff = diff
+		base.DiffFiles = sharediff.SummarizeAll(diff)
+		base.Symbols = goSymbolChanges(ctx, client, owner, repo, prMergeBase(ctx, client, owner, repo, pr), pr.GetHead().GetSHA(), base.DiffFiles)
+	}
+
+	target, err := resolveRevertTarget(ctx, client, owner, repo, pr)
+	if err != nil {
+		log.Printf("[ingest] revert target lookup failed for #%d: %v", pr.GetNumber(), err)
+	} else {
+		base.applyRevertTarget(target)
 	}
 
 	out.Reverted = append(out.Reverted, base)
@@ -206,36 +253,205 @@ if signal != nil {
 				base.Authorship = "human"
 			}
 
-			text := strings.ToLower(base.Title + " " + base.Body)
-
-			switch {
-			case base.Authorship == "bot":
-				base.RejectionReason = "bot_generated"
-			case strings.Contains(text, "rate limit"):
-				base.RejectionReason = "rate_limited"
-			case strings.Contains(text, "skip review"):
-				base.RejectionReason = "review_skipped"
-			default:
-				base.RejectionReason = "manual"
+			signals, err := classifyRejection(ctx, client, owner, repo, pr, base.Comments)
+			if err != nil {
+				log.Printf("[ingest] classify rejection failed for #%d: %v", pr.GetNumber(), err)
+				signals = []RejectionSignal{{Reason: "manual", Confidence: 0.3}}
 			}
 
+			base.RejectionReason = signals[0].Reason
+			base.RejectionConfidence = signals[0].Confidence
+			base.RejectionSignals = signals
+
 			out.Rejected = append(out.Rejected, base)
+			continue
+		}
+
+		files, _, err := client.PullRequests.ListFiles(
+			ctx,
+			owner,
+			repo,
+			pr.GetNumber(),
+			&github.ListOptions{PerPage: 100},
+		)
+		if err != nil {
+			log.Printf("[ingest] list files failed for #%d: %v", pr.GetNumber(), err)
+			continue
+		}
+		for _, f := range files {
+			base.Files = append(base.Files, f.GetFilename())
+		}
+		base.DiffFiles = summarizeCommitFiles(files)
+
+		// The branch commits let co-change mining tell the PR's own commits
+		// apart in the default-branch history.
+		commits, _, err := client.PullRequests.ListCommits(
+			ctx,
+			owner,
+			repo,
+			pr.GetNumber(),
+			&github.ListOptions{PerPage: 100},
+		)
+		if err != nil {
+			log.Printf("[ingest] list commits failed for #%d: %v", pr.GetNumber(), err)
+		}
+		for _, c := range commits {
+			base.CommitSHAs = append(base.CommitSHAs, c.GetSHA())
+		}
+		// Every symbol change costs two content reads per file, so merged PRs
+		// only get them up to the configured count.
+		if symbolPRs < MaxMergedSymbolPRs && hasGoSource(base.DiffFiles) {
+			symbolPRs++
+			base.Symbols = goSymbolChanges(ctx, client, owner, repo, prMergeBase(ctx, client, owner, repo, pr), pr.GetHead().GetSHA(), base.DiffFiles)
+		}
+
+		// A PR without review stats is still a valid merged PR.
+		review, err := collectReviewStats(ctx, client, owner, repo, pr, reviewComments, files)
+		if err != nil {
+			log.Printf("[ingest] review stats failed for #%d: %v", pr.GetNumber(), err)
 		}
+		base.Review = review
 
+		out.Merged = append(out.Merged, base)
 	}
 
 	log.Printf(
-		"[ingest] completed | reverted=%d rejected=%d",
+		"[ingest] completed | reverted=%d rejected=%d merged=%d",
 		len(out.Reverted),
 		len(out.Rejected),
+		len(out.Merged),
 	)
 
 	_ = writeJSON("reverted.json", out.Reverted)
 	_ = writeJSON("rejected.json", out.Rejected)
+	_ = writeJSON("merged.json", out.Merged)
 
 	return out, nil
 }
 
+// listClosedPRs returns the latest page of closed PRs for the rolling
+// window. The list endpoint cannot filter by date, so a bounded window goes
+// through search instead and reads every match search will page to. total is
+// the number of matches, which can exceed what was returned.
+func listClosedPRs(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	w Window,
+) ([]*github.PullRequest, int, error) {
+
+	if !w.Bounded() {
+		prs, _, err := client.PullRequests.List(
+			ctx,
+			owner,
+			repo,
+			&github.PullRequestListOptions{
+				State:       "closed",
+				Sort:        "updated",
+				Direction:   "desc",
+				ListOptions: github.ListOptions{PerPage: 50},
+			},
+		)
+		return prs, len(prs), err
+	}
+
+	opt := &github.SearchOptions{
+		Sort:        "created",
+		Order:       "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var out []*github.PullRequest
+	total := 0
+	for {
+		res, resp, err := client.Search.Issues(ctx, closedPRQuery(owner, repo, w), opt)
+		if err != nil {
+			return nil, 0, err
+		}
+		total = res.GetTotal()
+		for _, issue := range res.Issues {
+			pr, _, err := client.PullRequests.Get(ctx, owner, repo, issue.GetNumber())
+			if ctx.Err() != nil {
+				return nil, 0, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("[ingest] get PR #%d failed: %v", issue.GetNumber(), err)
+				continue
+			}
+			out = append(out, pr)
+		}
+		if resp.NextPage == 0 || resp.NextPage*opt.PerPage > maxSearchResults {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if total > maxSearchResults {
+		log.Printf("[ingest] %s/%s has %d closed PRs in %s, search stops at %d", owner, repo, total, w.searchRange(), maxSearchResults)
+	}
+	return out, total, nil
+}
+
+// CountClosedPRs returns how many PRs closed within the bounded window w,
+// so a backfill can size its slices before fetching them.
+func CountClosedPRs(ctx context.Context, client *github.Client, owner, repo string, w Window) (int, error) {
+	res, _, err := client.Search.Issues(ctx, closedPRQuery(owner, repo, w), &github.SearchOptions{
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.GetTotal(), nil
+}
+
+func closedPRQuery(owner, repo string, w Window) string {
+	return fmt.Sprintf("repo:%s/%s is:pr is:closed closed:%s", owner, repo, w.searchRange())
+}
+
+func minimalComment(u *github.User, body string, at time.Time) MinimalComment {
+	isBot := u.GetType() == "Bot"
+	return MinimalComment{
+		Author:     u.GetLogin(),
+		AuthorType: map[bool]string{true: "bot", false: "human"}[isBot],
+		IsBot:      isBot,
+		Body:       body,
+		CreatedAt:  at,
+	}
+}
+
+// summarizeCommitFiles builds diff summaries from the per-file patches the
+// files endpoint already returned, so merged PRs need no raw diff. GitHub
+// leaves out the patch of binary and very large files; those keep their
+// path and counts only.
+func summarizeCommitFiles(files []*github.CommitFile) []sharediff.FileSummary {
+	out := make([]sharediff.FileSummary, 0, len(files))
+	for _, f := range files {
+		hunks := sharediff.ParseHunks(f.GetPatch())
+		s := sharediff.FileSummary{
+			Path:      f.GetFilename(),
+			OldPath:   f.GetPreviousFilename(),
+			Status:    sharediff.Modified,
+			Binary:    f.GetPatch() == "" && f.GetChanges() == 0,
+			Additions: f.GetAdditions(),
+			Deletions: f.GetDeletions(),
+			Added:     sharediff.AddedRanges(hunks),
+			Removed:   sharediff.RemovedRanges(hunks),
+			Functions: sharediff.Functions(hunks),
+		}
+		switch f.GetStatus() {
+		case "added":
+			s.Status = sharediff.Created
+		case "removed":
+			s.Status = sharediff.Deleted
+		case "renamed":
+			s.Status = sharediff.Renamed
+		case "copied":
+			s.Status = sharediff.Copied
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
 func writeJSON(path string, v any) error {
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
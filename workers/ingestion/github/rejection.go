@@ -0,0 +1,254 @@
+package github
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const abandonedAfter = 30 * 24 * time.Hour
+
+type RejectionSignal struct {
+	Reason     string  `json:"reason"`
+	Confidence float32 `json:"confidence"`
+	Evidence   string  `json:"evidence,omitempty"`
+}
+
+// supersededCommentRegex requires a reference to the replacing PR, so prose
+// like "in favour of a simpler approach" does not count.
+var supersededCommentRegex = regexp.MustCompile(`(?i)\b(superseded by|in favou?r of|replaced by|duplicate of)\s+(?:[\w.-]+/[\w.-]+#\d+|#\d+|https?://github\.com/[\w.-]+/[\w.-]+/(?:pull|issues)/\d+)`)
+
+var abandonHints = []string{
+	"no longer needed", "not needed anymore", "won't fix", "wontfix",
+	"not planned", "closing this", "abandon",
+}
+
+// classifyRejection collects every reason a closed, unmerged PR may have been
+// rejected, ordered by confidence. The first entry is the primary reason.
+func classifyRejection(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	pr *github.PullRequest,
+	comments []MinimalComment,
+) ([]RejectionSignal, error) {
+
+	var out []RejectionSignal
+	closedAt := pr.GetClosedAt().Time
+	author := pr.GetUser().GetLogin()
+	lastActivity := pr.GetCreatedAt().Time
+
+	if pr.GetUser().GetType() == "Bot" {
+		out = append(out, RejectionSignal{Reason: "bot_generated", Confidence: 0.9})
+	}
+
+	reviews, _, err := client.PullRequests.ListReviews(
+		ctx,
+		owner,
+		repo,
+		pr.GetNumber(),
+		&github.ListOptions{PerPage: 100},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]*github.PullRequestReview{}
+	for _, r := range reviews {
+		if r.GetState() == "COMMENTED" {
+			continue
+		}
+		latest[r.GetUser().GetLogin()] = r
+		if t := r.GetSubmittedAt().Time; t.After(lastActivity) {
+			lastActivity = t
+		}
+	}
+	logins := make([]string, 0, len(latest))
+	for login := range latest {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	for _, login := range logins {
+		if latest[login].GetState() == "CHANGES_REQUESTED" {
+			out = append(out, RejectionSignal{
+				Reason:     "changes_requested",
+				Confidence: 0.85,
+				Evidence:   "changes requested by " + login,
+			})
+			break
+		}
+	}
+
+	timeline, _, err := client.Issues.ListIssueTimeline(
+		ctx,
+		owner,
+		repo,
+		pr.GetNumber(),
+		&github.ListOptions{PerPage: 100},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	staleLabel := false
+	for _, t := range timeline {
+		switch t.GetEvent() {
+		case "labeled":
+			if strings.Contains(strings.ToLower(t.GetLabel().GetName()), "stale") {
+				staleLabel = true
+			}
+		case "closed":
+			closer := t.GetActor()
+			if closer.GetType() == "Bot" && (staleLabel || strings.Contains(strings.ToLower(closer.GetLogin()), "stale")) {
+				out = append(out, RejectionSignal{
+					Reason:     "stale",
+					Confidence: 0.95,
+					Evidence:   "closed by " + closer.GetLogin(),
+				})
+				staleLabel = false
+			} else if closer.GetLogin() == author && len(latest) == 0 {
+				out = append(out, RejectionSignal{
+					Reason:     "abandoned",
+					Confidence: 0.5,
+					Evidence:   "closed by author without review",
+				})
+			}
+		case "committed":
+			if ts := t.GetCommitter().GetDate().Time; ts.After(lastActivity) && ts.Before(closedAt) {
+				lastActivity = ts
+			}
+		case "commented":
+			if ts := t.GetCreatedAt().Time; ts.After(lastActivity) && ts.Before(closedAt) {
+				lastActivity = ts
+			}
+		}
+	}
+	if staleLabel {
+		out = append(out, RejectionSignal{Reason: "stale", Confidence: 0.8, Evidence: "stale label"})
+	}
+
+	if branch := pr.GetHead().GetRef(); branch != "" && pr.GetHead().GetRepo() != nil {
+		siblings, _, err := client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+			State:       "closed",
+			Head:        pr.GetHead().GetRepo().GetOwner().GetLogin() + ":" + branch,
+			ListOptions: github.ListOptions{PerPage: 20},
+		})
+		if err == nil {
+			for _, s := range siblings {
+				if s.GetNumber() != pr.GetNumber() && s.MergedAt != nil {
+					out = append(out, RejectionSignal{
+						Reason:     "superseded",
+						Confidence: 0.95,
+						Evidence:   "branch merged via #" + strconv.Itoa(s.GetNumber()),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	human := make([]MinimalComment, 0, len(comments))
+	for _, c := range comments {
+		if c.IsBot {
+			continue
+		}
+		human = append(human, c)
+		if c.CreatedAt.After(lastActivity) && c.CreatedAt.Before(closedAt) {
+			lastActivity = c.CreatedAt
+		}
+	}
+	sort.Slice(human, func(i, j int) bool {
+		return human[i].CreatedAt.Before(human[j].CreatedAt)
+	})
+	if len(human) > 0 {
+		final := human[len(human)-1].Body
+		if m := supersededCommentRegex.FindString(final); m != "" {
+			out = append(out, RejectionSignal{Reason: "superseded", Confidence: 0.8, Evidence: m})
+		} else {
+			low := strings.ToLower(final)
+			for _, hint := range abandonHints {
+				if strings.Contains(low, hint) {
+					out = append(out, RejectionSignal{Reason: "abandoned", Confidence: 0.6, Evidence: hint})
+					break
+				}
+			}
+		}
+	}
+
+	if sha := pr.GetHead().GetSHA(); sha != "" {
+		if reason := ciStateAt(ctx, client, owner, repo, sha); reason != "" {
+			out = append(out, RejectionSignal{Reason: "failed_ci", Confidence: 0.75, Evidence: reason})
+		}
+	}
+
+	if !closedAt.IsZero() && closedAt.Sub(lastActivity) > abandonedAfter {
+		out = append(out, RejectionSignal{
+			Reason:     "abandoned",
+			Confidence: 0.7,
+			Evidence:   "no activity for " + strconv.Itoa(int(closedAt.Sub(lastActivity).Hours()/24)) + " days before close",
+		})
+	}
+
+	text := strings.ToLower(pr.GetTitle() + " " + pr.GetBody())
+	switch {
+	case strings.Contains(text, "rate limit"):
+		out = append(out, RejectionSignal{Reason: "rate_limited", Confidence: 0.5})
+	case strings.Contains(text, "skip review"):
+		out = append(out, RejectionSignal{Reason: "review_skipped", Confidence: 0.5})
+	}
+
+	if len(out) == 0 {
+		out = append(out, RejectionSignal{Reason: "manual", Confidence: 0.3})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Confidence > out[j].Confidence
+	})
+	return dedupeRejections(out), nil
+}
+
+// ciStateAt returns a short description of the failing status or check run at
+// sha, or "" if CI was green or absent.
+func ciStateAt(ctx context.Context, client *github.Client, owner, repo, sha string) string {
+	status, _, err := client.Repositories.GetCombinedStatus(ctx, owner, repo, sha, nil)
+	if err == nil {
+		for _, s := range status.Statuses {
+			if st := s.GetState(); st == "failure" || st == "error" {
+				return s.GetContext() + ": " + st
+			}
+		}
+	}
+
+	runs, _, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, &github.ListCheckRunsOptions{
+		ListOptions: github.ListOptions{PerPage: 50},
+	})
+	if err == nil {
+		for _, r := range runs.CheckRuns {
+			switch r.GetConclusion() {
+			case "failure", "timed_out":
+				return r.GetName() + ": " + r.GetConclusion()
+			}
+		}
+	}
+
+	return ""
+}
+
+// dedupeRejections keeps the highest-confidence signal for each reason.
+func dedupeRejections(in []RejectionSignal) []RejectionSignal {
+	seen := map[string]bool{}
+	out := in[:0]
+	for _, s := range in {
+		if seen[s.Reason] {
+			continue
+		}
+		seen[s.Reason] = true
+		out = append(out, s)
+	}
+	return out
+}
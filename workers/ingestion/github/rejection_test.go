@@ -0,0 +1,23 @@
+package github
+
+import "testing"
+
+func TestSupersededCommentRegex(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    string
+	}{
+		{"Superseded by #123", "Superseded by #123"},
+		{"closing in favor of #45, thanks!", "in favor of #45"},
+		{"Replaced by acme/widgets#9", "Replaced by acme/widgets#9"},
+		{"duplicate of https://github.com/acme/widgets/pull/77", "duplicate of https://github.com/acme/widgets/pull/77"},
+		{"closing in favour of a simpler approach", ""},
+		{"this was replaced by the new API", ""},
+		{"superseded by 12 other changes", ""},
+	}
+	for _, tt := range tests {
+		if got := supersededCommentRegex.FindString(tt.comment); got != tt.want {
+			t.Errorf("FindString(%q) = %q, want %q", tt.comment, got, tt.want)
+		}
+	}
+}
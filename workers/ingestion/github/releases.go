@@ -0,0 +1,423 @@
+package github
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	maxDeployments  = 100
+	maxReleases     = 30
+	maxShipCompares = 30
+
+	// maxShipComparePages bounds the commit pages read per shipped range.
+	maxShipComparePages = 5
+
+	// maxStatusReads bounds the deployments whose statuses are read, one
+	// call each, newest first and in the primary environment only.
+	maxStatusReads = 30
+)
+
+var (
+	squashPRRegex = regexp.MustCompile(`\(#(\d+)\)\s*$`)
+	mergePRRegex  = regexp.MustCompile(`^Merge pull request #(\d+)`)
+)
+
+type DeploymentStatus struct {
+	State       string    `json:"state"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Deployment is one GitHub deployment with its status history. RolledBack is
+// set when a later deployment to the same environment went back to a SHA that
+// had been live before this one. Only recent deployments to the primary
+// environment carry statuses.
+type Deployment struct {
+	ID          int64              `json:"id"`
+	SHA         string             `json:"sha"`
+	Ref         string             `json:"ref"`
+	Environment string             `json:"environment"`
+	Creator     string             `json:"creator"`
+	CreatedAt   time.Time          `json:"created_at"`
+	State       string             `json:"state"`
+	Statuses    []DeploymentStatus `json:"statuses,omitempty"`
+	Failed      bool               `json:"failed"`
+	RolledBack  bool               `json:"rolled_back"`
+	RollbackTo  string             `json:"rollback_to,omitempty"`
+	PRs         []int              `json:"prs,omitempty"`
+}
+
+type Release struct {
+	Tag         string     `json:"tag"`
+	Name        string     `json:"name,omitempty"`
+	SHA         string     `json:"sha,omitempty"`
+	HTMLURL     string     `json:"html_url,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Prerelease  bool       `json:"prerelease"`
+	TagOnly     bool       `json:"tag_only,omitempty"`
+	RolledBack  bool       `json:"rolled_back"`
+	PRs         []int      `json:"prs,omitempty"`
+}
+
+type ReleaseHistory struct {
+	Environment string       `json:"primary_environment,omitempty"`
+	Deployments []Deployment `json:"deployments"`
+	Releases    []Release    `json:"releases"`
+}
+
+// FetchReleaseHistory loads deployments, releases and tags, works out which
+// PRs shipped in each, and annotates the given PRs with AnnotateReleases.
+func FetchReleaseHistory(
+	ctx context.Context,
+	client *github.Client,
+	owner string,
+	repo string,
+	prs ...[]MinimalPR,
+) (*ReleaseHistory, error) {
+
+	log.Printf("[ingest] fetching deployments and releases for %s/%s", owner, repo)
+
+	bySHA := map[string]int{}
+	for _, bucket := range prs {
+		for _, p := range bucket {
+			if p.Number != 0 && p.MergeCommitSHA != "" {
+				bySHA[p.MergeCommitSHA] = p.Number
+			}
+		}
+	}
+	shipped := func(commits []*github.RepositoryCommit) []int {
+		seen := map[int]bool{}
+		var out []int
+		for _, c := range commits {
+			n := commitPRNumber(c, bySHA)
+			if n != 0 && !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+		sort.Ints(out)
+		return out
+	}
+
+	out := &ReleaseHistory{}
+
+	deployments, err := fetchDeployments(ctx, client, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	out.Environment = primaryEnvironment(deployments)
+	if err := fetchDeploymentStatuses(ctx, client, owner, repo, deployments, out.Environment); err != nil {
+		return nil, err
+	}
+	detectRollbacks(deployments)
+
+	compares := 0
+	var prev *Deployment
+	for i := range deployments {
+		d := &deployments[i]
+		if d.Environment != out.Environment || d.Failed {
+			continue
+		}
+		if prev != nil && prev.SHA != d.SHA && compares < maxShipCompares {
+			compares++
+			cmp, err := compareAll(ctx, client, owner, repo, prev.SHA, d.SHA, maxShipComparePages)
+			if err == nil && cmp.GetStatus() == "ahead" {
+				d.PRs = shipped(cmp.Commits)
+			}
+		}
+		prev = d
+	}
+
+	releases, err := fetchReleases(ctx, client, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	rolledBack := map[string]bool{}
+	for _, d := range deployments {
+		if d.RolledBack {
+			rolledBack[d.SHA] = true
+		}
+	}
+
+	var prevRel *Release
+	for i := range releases {
+		r := &releases[i]
+		r.RolledBack = r.SHA != "" && rolledBack[r.SHA]
+		if r.TagOnly {
+			continue
+		}
+		if prevRel != nil && compares < maxShipCompares {
+			compares++
+			cmp, err := compareAll(ctx, client, owner, repo, prevRel.Tag, r.Tag, maxShipComparePages)
+			if err == nil && cmp.GetStatus() == "ahead" {
+				r.PRs = shipped(cmp.Commits)
+			}
+		}
+		prevRel = r
+	}
+
+	out.Deployments = deployments
+	out.Releases = releases
+	AnnotateReleases(out, prs...)
+
+	log.Printf(
+		"[ingest] release history | deployments=%d releases=%d environment=%q",
+		len(deployments),
+		len(releases),
+		out.Environment,
+	)
+	_ = writeJSON("releases.json", out)
+	return out, nil
+}
+
+// AnnotateReleases sets, on each merged PR, the first release and first
+// primary-environment deployment that shipped it and whether either was
+// rolled back.
+func AnnotateReleases(h *ReleaseHistory, prs ...[]MinimalPR) {
+	if h == nil {
+		return
+	}
+
+	byNumber := map[int]*MinimalPR{}
+	for _, bucket := range prs {
+		for i := range bucket {
+			p := &bucket[i]
+			if p.Number != 0 && p.MergedAt != nil {
+				byNumber[p.Number] = p
+			}
+		}
+	}
+
+	for _, d := range h.Deployments {
+		for _, n := range d.PRs {
+			p := byNumber[n]
+			if p == nil || p.DeployedAt != nil {
+				continue
+			}
+			at := d.CreatedAt
+			p.DeployedAt = &at
+			p.DeployEnvironment = d.Environment
+			p.MergeToDeployHours = at.Sub(*p.MergedAt).Hours()
+			p.DeployRolledBack = d.RolledBack
+		}
+	}
+
+	for _, r := range h.Releases {
+		for _, n := range r.PRs {
+			p := byNumber[n]
+			if p == nil || p.Release != "" {
+				continue
+			}
+			p.Release = r.Tag
+			p.ReleaseRolledBack = r.RolledBack
+		}
+	}
+}
+
+func commitPRNumber(c *github.RepositoryCommit, bySHA map[string]int) int {
+	if n, ok := bySHA[c.GetSHA()]; ok {
+		return n
+	}
+	subject := strings.SplitN(c.GetCommit().GetMessage(), "\n", 2)[0]
+	for _, re := range []*regexp.Regexp{squashPRRegex, mergePRRegex} {
+		if m := re.FindStringSubmatch(subject); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// fetchDeployments returns recent deployments oldest first, without their
+// statuses.
+func fetchDeployments(ctx context.Context, client *github.Client, owner, repo string) ([]Deployment, error) {
+	list, _, err := client.Repositories.ListDeployments(
+		ctx,
+		owner,
+		repo,
+		&github.DeploymentsListOptions{ListOptions: github.ListOptions{PerPage: maxDeployments}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Deployment, 0, len(list))
+	for _, d := range list {
+		out = append(out, Deployment{
+			ID:          d.GetID(),
+			SHA:         d.GetSHA(),
+			Ref:         d.GetRef(),
+			Environment: d.GetEnvironment(),
+			Creator:     d.GetCreator().GetLogin(),
+			CreatedAt:   d.GetCreatedAt().Time,
+		})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+// fetchDeploymentStatuses loads the status page of the newest maxStatusReads
+// deployments to env. Statuses cost one call per deployment, and only the
+// primary environment is used for shipping and rollbacks, so the others are
+// left without a state.
+func fetchDeploymentStatuses(ctx context.Context, client *github.Client, owner, repo string, deployments []Deployment, env string) error {
+	reads := 0
+	for i := len(deployments) - 1; i >= 0 && reads < maxStatusReads; i-- {
+		dep := &deployments[i]
+		if dep.Environment != env {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		reads++
+
+		statuses, _, err := client.Repositories.ListDeploymentStatuses(
+			ctx,
+			owner,
+			repo,
+			dep.ID,
+			&github.ListOptions{PerPage: 30},
+		)
+		if err != nil {
+			log.Printf("[ingest] deployment %d statuses failed: %v", dep.ID, err)
+		}
+		// Statuses come back newest first.
+		for i := len(statuses) - 1; i >= 0; i-- {
+			s := statuses[i]
+			dep.Statuses = append(dep.Statuses, DeploymentStatus{
+				State:       s.GetState(),
+				Description: s.GetDescription(),
+				CreatedAt:   s.GetCreatedAt().Time,
+			})
+		}
+		if len(statuses) > 0 {
+			dep.State = statuses[0].GetState()
+		}
+		dep.Failed = dep.State == "failure" || dep.State == "error"
+	}
+	return nil
+}
+
+// detectRollbacks walks each environment in order. A successful deployment of
+// a SHA that was already live earlier, right after a different SHA, means the
+// deployment in between was rolled back. Superseded deployments are marked
+// "inactive" by GitHub, so that counts as having been live.
+func detectRollbacks(deployments []Deployment) {
+	live := func(d *Deployment) bool {
+		return d.State == "success" || d.State == "inactive"
+	}
+
+	lastLive := map[string]*Deployment{}
+	everLive := map[string]map[string]bool{}
+
+	for i := range deployments {
+		d := &deployments[i]
+		if !live(d) {
+			continue
+		}
+		env := d.Environment
+		if everLive[env] == nil {
+			everLive[env] = map[string]bool{}
+		}
+
+		if prev := lastLive[env]; prev != nil && prev.SHA != d.SHA && everLive[env][d.SHA] {
+			prev.RolledBack = true
+			prev.RollbackTo = d.SHA
+		}
+
+		everLive[env][d.SHA] = true
+		lastLive[env] = d
+	}
+}
+
+// primaryEnvironment prefers an environment named like production and falls
+// back to the one deployed most often.
+func primaryEnvironment(deployments []Deployment) string {
+	counts := map[string]int{}
+	for _, d := range deployments {
+		counts[d.Environment]++
+	}
+
+	best, bestCount := "", 0
+	for env, n := range counts {
+		lower := strings.ToLower(env)
+		if lower == "production" || lower == "prod" {
+			return env
+		}
+		if n > bestCount || n == bestCount && env < best {
+			best, bestCount = env, n
+		}
+	}
+	return best
+}
+
+// fetchReleases returns published releases oldest first, followed by tags
+// that have no release.
+func fetchReleases(ctx context.Context, client *github.Client, owner, repo string) ([]Release, error) {
+	tags, _, err := client.Repositories.ListTags(ctx, owner, repo, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, err
+	}
+	tagSHA := map[string]string{}
+	for _, t := range tags {
+		tagSHA[t.GetName()] = t.GetCommit().GetSHA()
+	}
+
+	list, _, err := client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{PerPage: maxReleases})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Release
+	released := map[string]bool{}
+	for _, r := range list {
+		if r.GetDraft() {
+			continue
+		}
+		rel := Release{
+			Tag:        r.GetTagName(),
+			Name:       r.GetName(),
+			SHA:        tagSHA[r.GetTagName()],
+			HTMLURL:    r.GetHTMLURL(),
+			Prerelease: r.GetPrerelease(),
+		}
+		if r.PublishedAt != nil {
+			rel.PublishedAt = &r.PublishedAt.Time
+		}
+		released[rel.Tag] = true
+		out = append(out, rel)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].PublishedAt == nil || out[j].PublishedAt == nil {
+			return out[j].PublishedAt == nil && out[i].PublishedAt != nil
+		}
+		return out[i].PublishedAt.Before(*out[j].PublishedAt)
+	})
+
+	for _, t := range tags {
+		if released[t.GetName()] || len(out) >= maxReleases*2 {
+			continue
+		}
+		out = append(out, Release{
+			Tag:     t.GetName(),
+			SHA:     t.GetCommit().GetSHA(),
+			TagOnly: true,
+		})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,228 @@
+package github
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+
+	"codrel-sentinel/workers/shared/diff"
+	"codrel-sentinel/workers/shared/symbols"
+)
+
+var (
+	revertsCommitRegex = regexp.MustCompile(`(?i)this reverts commit ([0-9a-f]{7,40})`)
+	revertsPRRegex     = regexp.MustCompile(`(?i)\breverts\s+(?:([\w.-]+)/([\w.-]+))?#(\d+)`)
+	revertBranchRegex  = regexp.MustCompile(`^revert-(\d+)-`)
+)
+
+// RevertTarget describes the change a revert undid.
+type RevertTarget struct {
+	SHAs         []string
+	PRNumber     int
+	PRAuthor     string
+	MergedAt     *time.Time
+	Diff         string
+	Symbols      []symbols.Change
+	TimeToRevert time.Duration
+}
+
+// parseRevertedSHAs returns every commit SHA named in a
+// "This reverts commit <sha>" trailer, in order of appearance.
+func parseRevertedSHAs(texts ...string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range texts {
+		for _, m := range revertsCommitRegex.FindAllStringSubmatch(t, -1) {
+			sha := strings.ToLower(m[1])
+			if !seen[sha] {
+				seen[sha] = true
+				out = append(out, sha)
+			}
+		}
+	}
+	return out
+}
+
+// parseRevertedPR returns the PR number from a "Reverts owner/repo#N" body
+// (as written by GitHub's revert button) when it points at the same repo.
+func parseRevertedPR(owner, repo, body string) int {
+	for _, m := range revertsPRRegex.FindAllStringSubmatch(body, -1) {
+		if m[1] != "" && (!strings.EqualFold(m[1], owner) || !strings.EqualFold(m[2], repo)) {
+			continue
+		}
+		if n, err := strconv.Atoi(m[3]); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// resolveRevertTarget finds the PR and commits a revert PR undid and loads the
+// original diff, so that risk is attributed to the reverted code.
+func resolveRevertTarget(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	pr *github.PullRequest,
+) (*RevertTarget, error) {
+
+	commits, _, err := client.PullRequests.ListCommits(
+		ctx,
+		owner,
+		repo,
+		pr.GetNumber(),
+		&github.ListOptions{PerPage: 30},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := []string{pr.GetBody()}
+	for _, c := range commits {
+		texts = append(texts, c.GetCommit().GetMessage())
+	}
+	shas := parseRevertedSHAs(texts...)
+
+	number := parseRevertedPR(owner, repo, pr.GetBody())
+	if number == 0 {
+		number = findMergedPRForSHAs(ctx, client, owner, repo, shas, pr.GetNumber())
+	}
+	if number == 0 {
+		if m := revertBranchRegex.FindStringSubmatch(pr.GetHead().GetRef()); m != nil {
+			number, _ = strconv.Atoi(m[1])
+		}
+	}
+	if number == pr.GetNumber() {
+		number = 0
+	}
+
+	revertedAt := pr.GetCreatedAt().Time
+	if pr.MergedAt != nil {
+		revertedAt = pr.MergedAt.Time
+	}
+
+	return loadRevertTarget(ctx, client, owner, repo, shas, number, revertedAt), nil
+}
+
+// findMergedPRForSHAs returns the first merged PR, other than exclude, that
+// contains one of the given commits.
+func findMergedPRForSHAs(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	shas []string,
+	exclude int,
+) int {
+	for _, sha := range shas {
+		prs, _, err := client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, nil)
+		if err != nil {
+			continue
+		}
+		for _, p := range prs {
+			if p.GetNumber() != exclude && p.MergedAt != nil {
+				return p.GetNumber()
+			}
+		}
+	}
+	return 0
+}
+
+// loadRevertTarget fetches the original PR (or, failing that, the reverted
+// commits) and returns nil when nothing could be identified.
+func loadRevertTarget(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	shas []string,
+	number int,
+	revertedAt time.Time,
+) *RevertTarget {
+
+	target := &RevertTarget{SHAs: shas}
+
+	// Refs the diff was taken between, for symbol extraction.
+	var baseRef, headRef string
+
+	if number != 0 {
+		original, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+		if err == nil {
+			target.PRNumber = original.GetNumber()
+			target.PRAuthor = original.GetUser().GetLogin()
+			if original.MergedAt != nil {
+				target.MergedAt = &original.MergedAt.Time
+			}
+			baseRef = prMergeBase(ctx, client, owner, repo, original)
+			headRef = original.GetHead().GetSHA()
+
+			diff, _, err := client.PullRequests.GetRaw(
+				ctx,
+				owner,
+				repo,
+				number,
+				github.RawOptions{Type: github.Diff},
+			)
+			if err == nil {
+				target.Diff = diff
+			}
+		}
+	}
+
+	if target.Diff == "" && len(shas) > 0 {
+		var diffs []string
+		for _, sha := range shas {
+			diff, _, err := client.Repositories.GetCommitRaw(
+				ctx,
+				owner,
+				repo,
+				sha,
+				github.RawOptions{Type: github.Diff},
+			)
+			if err != nil {
+				continue
+			}
+			diffs = append(diffs, diff)
+
+			if target.MergedAt == nil || len(shas) == 1 {
+				if c, _, err := client.Repositories.GetCommit(ctx, owner, repo, sha, nil); err == nil {
+					if target.MergedAt == nil {
+						t := c.GetCommit().GetCommitter().GetDate().Time
+						target.MergedAt = &t
+					}
+					if len(shas) == 1 && len(c.Parents) > 0 {
+						baseRef, headRef = c.Parents[0].GetSHA(), sha
+					}
+				}
+			}
+		}
+		target.Diff = strings.Join(diffs, "\n")
+	}
+
+	target.Symbols = goSymbolChanges(ctx, client, owner, repo, baseRef, headRef, diff.SummarizeAll(target.Diff))
+
+	if target.MergedAt != nil {
+		target.TimeToRevert = revertedAt.Sub(*target.MergedAt)
+	}
+
+	if target.PRNumber == 0 && len(target.SHAs) == 0 {
+		return nil
+	}
+	return target
+}
+
+func (p *MinimalPR) applyRevertTarget(t *RevertTarget) {
+	if t == nil {
+		return
+	}
+	p.RevertedSHAs = t.SHAs
+	p.OriginalPRNumber = t.PRNumber
+	p.OriginalPRAuthor = t.PRAuthor
+	p.OriginalMergedAt = t.MergedAt
+	p.TimeToRevertHours = t.TimeToRevert.Hours()
+	p.OriginalDiff = t.Diff
+	p.OriginalDiffFiles = diff.SummarizeAll(t.Diff)
+	p.OriginalSymbols = t.Symbols
+}
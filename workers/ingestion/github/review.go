@@ -0,0 +1,160 @@
+package github
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/go-github/v61/github"
+)
+
+type FileReviewStat struct {
+	Path         string  `json:"path"`
+	Comments     int     `json:"comments"`
+	Threads      int     `json:"threads"`
+	LinesTouched int     `json:"lines_touched"`
+	ChangedLines int     `json:"changed_lines"`
+	Density      float64 `json:"density"`
+}
+
+type ReviewStats struct {
+	Reviewers              []string         `json:"reviewers,omitempty"`
+	Approvers              []string         `json:"approvers,omitempty"`
+	ReviewRounds           int              `json:"review_rounds"`
+	ChangesRequested       int              `json:"changes_requested"`
+	TimeToFirstReviewHours float64          `json:"time_to_first_review_hours,omitempty"`
+	TimeToMergeHours       float64          `json:"time_to_merge_hours,omitempty"`
+	ReviewComments         int              `json:"review_comments"`
+	Files                  []FileReviewStat `json:"files,omitempty"`
+}
+
+// collectReviewStats summarises who reviewed a PR, how many rounds it took and
+// where review comments landed. Density is review comments per changed line of
+// each file, so files that routinely attract long threads stand out.
+func collectReviewStats(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	pr *github.PullRequest,
+	reviewComments []*github.PullRequestComment,
+	files []*github.CommitFile,
+) (*ReviewStats, error) {
+
+	reviews, _, err := client.PullRequests.ListReviews(
+		ctx,
+		owner,
+		repo,
+		pr.GetNumber(),
+		&github.ListOptions{PerPage: 100},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	author := pr.GetUser().GetLogin()
+	stats := &ReviewStats{ReviewComments: len(reviewComments)}
+
+	reviewers := map[string]bool{}
+	approvers := map[string]bool{}
+	rounds := map[string]bool{}
+	var firstReview *github.Timestamp
+
+	for _, r := range reviews {
+		u := r.GetUser()
+		if u.GetLogin() == author || u.GetType() == "Bot" {
+			continue
+		}
+
+		reviewers[u.GetLogin()] = true
+		if r.GetCommitID() != "" {
+			rounds[r.GetCommitID()] = true
+		}
+		switch r.GetState() {
+		case "APPROVED":
+			approvers[u.GetLogin()] = true
+		case "CHANGES_REQUESTED":
+			stats.ChangesRequested++
+		}
+
+		if r.SubmittedAt != nil && (firstReview == nil || r.SubmittedAt.Before(firstReview.Time)) {
+			firstReview = r.SubmittedAt
+		}
+	}
+
+	stats.Reviewers = sortedKeys(reviewers)
+	stats.Approvers = sortedKeys(approvers)
+	stats.ReviewRounds = len(rounds)
+
+	created := pr.GetCreatedAt().Time
+	if firstReview != nil {
+		stats.TimeToFirstReviewHours = firstReview.Sub(created).Hours()
+	}
+	if pr.MergedAt != nil {
+		stats.TimeToMergeHours = pr.MergedAt.Sub(created).Hours()
+	}
+
+	if len(reviewComments) == 0 {
+		return stats, nil
+	}
+
+	changed := map[string]int{}
+	for _, f := range files {
+		changed[f.GetFilename()] = f.GetAdditions() + f.GetDeletions()
+	}
+
+	byFile := map[string]*FileReviewStat{}
+	lines := map[string]map[int]bool{}
+	for _, c := range reviewComments {
+		path := c.GetPath()
+		if path == "" {
+			continue
+		}
+
+		fs, ok := byFile[path]
+		if !ok {
+			fs = &FileReviewStat{Path: path, ChangedLines: changed[path]}
+			byFile[path] = fs
+			lines[path] = map[int]bool{}
+		}
+
+		fs.Comments++
+		if c.GetInReplyTo() == 0 {
+			fs.Threads++
+		}
+
+		line := c.GetLine()
+		if line == 0 {
+			line = c.GetOriginalLine()
+		}
+		if line == 0 {
+			line = c.GetPosition()
+		}
+		if line != 0 {
+			lines[path][line] = true
+		}
+	}
+
+	for path, fs := range byFile {
+		fs.LinesTouched = len(lines[path])
+		if fs.ChangedLines > 0 {
+			fs.Density = float64(fs.Comments) / float64(fs.ChangedLines)
+		}
+		stats.Files = append(stats.Files, *fs)
+	}
+	sort.Slice(stats.Files, func(i, j int) bool {
+		if stats.Files[i].Comments != stats.Files[j].Comments {
+			return stats.Files[i].Comments > stats.Files[j].Comments
+		}
+		return stats.Files[i].Path < stats.Files[j].Path
+	})
+
+	return stats, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
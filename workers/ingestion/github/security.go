@@ -0,0 +1,336 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	securityPages        = 3
+	maxSecretLocations   = 5
+	alertSourceDeps      = "dependabot"
+	alertSourceCode      = "code_scanning"
+	alertSourceSecret    = "secret_scanning"
+	secretAlertsSeverity = "critical"
+)
+
+// SecurityAlert is one Dependabot, code-scanning or secret-scanning alert.
+// File is the manifest for dependency alerts and the flagged source file for
+// the others. The secret value of a secret-scanning alert is never copied.
+type SecurityAlert struct {
+	Source    string `json:"source"`
+	Number    int    `json:"number"`
+	State     string `json:"state"`
+	Severity  string `json:"severity"`
+	Rule      string `json:"rule"`
+	Summary   string `json:"summary"`
+	HTMLURL   string `json:"html_url"`
+	File      string `json:"file,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+
+	Package         string   `json:"package,omitempty"`
+	Ecosystem       string   `json:"ecosystem,omitempty"`
+	VulnerableRange string   `json:"vulnerable_range,omitempty"`
+	PatchedVersion  string   `json:"patched_version,omitempty"`
+	Identifiers     []string `json:"identifiers,omitempty"`
+	Tool            string   `json:"tool,omitempty"`
+	Locations       []string `json:"locations,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+// FetchSecurityAlerts pulls all three alert kinds. Each kind needs its own
+// feature and token scope, so a 403 or 404 from one only skips that kind and
+// is reported as an *AlertsUnavailableError next to the other alerts.
+func FetchSecurityAlerts(
+	ctx context.Context,
+	client *github.Client,
+	owner string,
+	repo string,
+) ([]SecurityAlert, error) {
+
+	log.Printf("[ingest] fetching security alerts for %s/%s", owner, repo)
+
+	var out []SecurityAlert
+	var unavailable []string
+	fetchers := []struct {
+		source string
+		fetch  func(context.Context, *github.Client, string, string) ([]SecurityAlert, error)
+	}{
+		{alertSourceDeps, fetchDependabotAlerts},
+		{alertSourceCode, fetchCodeScanningAlerts},
+		{alertSourceSecret, fetchSecretScanningAlerts},
+	}
+
+	for _, f := range fetchers {
+		alerts, err := f.fetch(ctx, client, owner, repo)
+		if err != nil {
+			if alertsUnavailable(err) {
+				log.Printf("[ingest] %s alerts unavailable for %s/%s: %v", f.source, owner, repo, err)
+				unavailable = append(unavailable, f.source)
+				continue
+			}
+			return nil, err
+		}
+		out = append(out, alerts...)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		ri, rj := severityRank(out[i].Severity), severityRank(out[j].Severity)
+		if ri != rj {
+			return ri > rj
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+
+	log.Printf("[ingest] security alerts | total=%d", len(out))
+	_ = writeJSON("security_alerts.json", out)
+	if len(unavailable) > 0 {
+		return out, &AlertsUnavailableError{Sources: unavailable, Total: len(fetchers)}
+	}
+	return out, nil
+}
+
+// AlertsUnavailableError lists the alert sources the repo or token did not
+// allow. Alerts from the remaining sources are still returned with it.
+type AlertsUnavailableError struct {
+	Sources []string
+	Total   int
+}
+
+func (e *AlertsUnavailableError) Error() string {
+	return fmt.Sprintf("%s alerts unavailable", strings.Join(e.Sources, ", "))
+}
+
+// All reports whether no source could be read at all.
+func (e *AlertsUnavailableError) All() bool {
+	return len(e.Sources) == e.Total
+}
+
+func alertsUnavailable(err error) bool {
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		switch ghErr.Response.StatusCode {
+		case http.StatusForbidden, http.StatusNotFound:
+			return true
+		}
+	}
+	return false
+}
+
+func fetchDependabotAlerts(ctx context.Context, client *github.Client, owner, repo string) ([]SecurityAlert, error) {
+	var out []SecurityAlert
+	opt := &github.ListAlertsOptions{
+		ListCursorOptions: github.ListCursorOptions{PerPage: 100},
+	}
+
+	for page := 0; page < securityPages; page++ {
+		alerts, resp, err := client.Dependabot.ListRepoAlerts(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range alerts {
+			adv := a.GetSecurityAdvisory()
+			vuln := a.GetSecurityVulnerability()
+
+			alert := SecurityAlert{
+				Source:          alertSourceDeps,
+				Number:          a.GetNumber(),
+				State:           a.GetState(),
+				Severity:        normalizeSeverity(adv.GetSeverity()),
+				Rule:            adv.GetGHSAID(),
+				Summary:         adv.GetSummary(),
+				HTMLURL:         a.GetHTMLURL(),
+				File:            a.GetDependency().GetManifestPath(),
+				Package:         a.GetDependency().GetPackage().GetName(),
+				Ecosystem:       a.GetDependency().GetPackage().GetEcosystem(),
+				VulnerableRange: vuln.GetVulnerableVersionRange(),
+				PatchedVersion:  vuln.GetFirstPatchedVersion().GetIdentifier(),
+				CreatedAt:       a.GetCreatedAt().Time,
+			}
+			for _, id := range []string{adv.GetCVEID(), adv.GetGHSAID()} {
+				if id != "" {
+					alert.Identifiers = append(alert.Identifiers, id)
+				}
+			}
+			switch {
+			case a.FixedAt != nil:
+				alert.ResolvedAt, alert.Resolution = &a.FixedAt.Time, "fixed"
+			case a.DismissedAt != nil:
+				alert.ResolvedAt, alert.Resolution = &a.DismissedAt.Time, a.GetDismissedReason()
+			}
+			out = append(out, alert)
+		}
+
+		if resp.After == "" {
+			break
+		}
+		opt.ListCursorOptions.After = resp.After
+	}
+
+	return out, nil
+}
+
+func fetchCodeScanningAlerts(ctx context.Context, client *github.Client, owner, repo string) ([]SecurityAlert, error) {
+	var out []SecurityAlert
+	opt := &github.AlertListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for page := 0; page < securityPages; page++ {
+		alerts, resp, err := client.CodeScanning.ListAlertsForRepo(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range alerts {
+			rule := a.GetRule()
+			inst := a.GetMostRecentInstance()
+			loc := inst.GetLocation()
+
+			severity := rule.GetSecuritySeverityLevel()
+			if severity == "" {
+				severity = rule.GetSeverity()
+			}
+
+			alert := SecurityAlert{
+				Source:    alertSourceCode,
+				Number:    a.GetNumber(),
+				State:     a.GetState(),
+				Severity:  normalizeSeverity(severity),
+				Rule:      rule.GetID(),
+				Summary:   firstNonEmpty(inst.GetMessage().GetText(), rule.GetDescription()),
+				HTMLURL:   a.GetHTMLURL(),
+				File:      loc.GetPath(),
+				StartLine: loc.GetStartLine(),
+				EndLine:   loc.GetEndLine(),
+				Tool:      a.GetTool().GetName(),
+				CreatedAt: a.GetCreatedAt().Time,
+			}
+			switch {
+			case a.FixedAt != nil:
+				alert.ResolvedAt, alert.Resolution = &a.FixedAt.Time, "fixed"
+			case a.DismissedAt != nil:
+				alert.ResolvedAt, alert.Resolution = &a.DismissedAt.Time, a.GetDismissedReason()
+			}
+			out = append(out, alert)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.ListOptions.Page = resp.NextPage
+	}
+
+	return out, nil
+}
+
+func fetchSecretScanningAlerts(ctx context.Context, client *github.Client, owner, repo string) ([]SecurityAlert, error) {
+	var out []SecurityAlert
+	opt := &github.SecretScanningAlertListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for page := 0; page < securityPages; page++ {
+		alerts, resp, err := client.SecretScanning.ListAlertsForRepo(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range alerts {
+			alert := SecurityAlert{
+				Source:    alertSourceSecret,
+				Number:    a.GetNumber(),
+				State:     a.GetState(),
+				Severity:  secretAlertsSeverity,
+				Rule:      a.GetSecretType(),
+				Summary:   firstNonEmpty(a.GetSecretTypeDisplayName(), a.GetSecretType()) + " committed to the repository",
+				HTMLURL:   a.GetHTMLURL(),
+				CreatedAt: a.GetCreatedAt().Time,
+			}
+			if a.ResolvedAt != nil {
+				alert.ResolvedAt, alert.Resolution = &a.ResolvedAt.Time, a.GetResolution()
+			}
+
+			locs, _, err := client.SecretScanning.ListLocationsForAlert(
+				ctx,
+				owner,
+				repo,
+				int64(a.GetNumber()),
+				&github.ListOptions{PerPage: maxSecretLocations},
+			)
+			if err != nil {
+				log.Printf("[ingest] secret alert #%d locations failed: %v", a.GetNumber(), err)
+			}
+			for _, l := range locs {
+				d := l.GetDetails()
+				if l.GetType() != "commit" || d.GetPath() == "" {
+					continue
+				}
+				if alert.File == "" {
+					alert.File = d.GetPath()
+					alert.StartLine = d.GetStartline()
+					alert.EndLine = d.GetEndLine()
+				}
+				alert.Locations = append(alert.Locations, d.GetPath())
+			}
+			out = append(out, alert)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.ListOptions.Page = resp.NextPage
+	}
+
+	return out, nil
+}
+
+// normalizeSeverity maps advisory levels and SARIF levels (error, warning,
+// note) onto low/medium/high/critical.
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(s) {
+	case "critical":
+		return "critical"
+	case "high", "error":
+		return "high"
+	case "medium", "moderate", "warning":
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+func severityRank(s string) int {
+	switch s {
+	case "critical":
+		return 3
+	case "high":
+		return 2
+	case "medium":
+		return 1
+	default:
+		return 0
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
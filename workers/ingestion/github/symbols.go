@@ -0,0 +1,226 @@
+package github
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v61/github"
+
+	sharediff "codrel-sentinel/workers/shared/diff"
+	"codrel-sentinel/workers/shared/symbols"
+)
+
+const maxSymbolFiles = 20
+
+// MaxMergedSymbolPRs is how many merged PRs per fetch get their Go symbol
+// changes computed. Reverted PRs always do; 0 leaves merged PRs out.
+var MaxMergedSymbolPRs = 0
+
+// SymbolStat is the revert history of one Go symbol across an ingestion job.
+type SymbolStat struct {
+	File     string       `json:"file"`
+	Name     string       `json:"name"`
+	Kind     symbols.Kind `json:"kind"`
+	Exported bool         `json:"exported"`
+	Reverts  int          `json:"reverts"`
+	Breaking int          `json:"breaking"`
+	Refs     []string     `json:"refs"`
+}
+
+// prMergeBase returns the commit a PR branched from. The base SHA GitHub
+// reports is the base branch tip, which may hold changes made on the branch
+// since; diffing against it would attribute them to the PR. An empty result
+// makes goSymbolChanges skip the PR.
+func prMergeBase(ctx context.Context, client *github.Client, owner, repo string, pr *github.PullRequest) string {
+	cmp, _, err := client.Repositories.CompareCommits(ctx, owner, repo, pr.GetBase().GetSHA(), pr.GetHead().GetSHA(), &github.ListOptions{PerPage: 1})
+	if err != nil {
+		log.Printf("[ingest] merge base lookup failed for #%d: %v", pr.GetNumber(), err)
+		return ""
+	}
+	return cmp.GetMergeBaseCommit().GetSHA()
+}
+
+func hasGoSource(files []sharediff.FileSummary) bool {
+	for _, f := range files {
+		if !f.Binary && isGoSource(f.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// goSymbolChanges loads every changed .go file at base and head and compares
+// their declarations. Files that fail to load or parse are skipped.
+func goSymbolChanges(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo string,
+	base, head string,
+	files []sharediff.FileSummary,
+) []symbols.Change {
+
+	if base == "" || head == "" {
+		return nil
+	}
+
+	var out []symbols.Change
+	n := 0
+	for _, f := range files {
+		if f.Binary || !isGoSource(f.Path) {
+			continue
+		}
+		if n >= maxSymbolFiles {
+			break
+		}
+		n++
+
+		var before, after []byte
+		var err error
+
+		if f.Status != sharediff.Created {
+			oldPath := f.Path
+			if f.OldPath != "" {
+				oldPath = f.OldPath
+			}
+			if before, err = fileAt(ctx, client, owner, repo, oldPath, base); err != nil {
+				log.Printf("[ingest] load %s@%.7s failed: %v", oldPath, base, err)
+				continue
+			}
+		}
+		if f.Status != sharediff.Deleted {
+			if after, err = fileAt(ctx, client, owner, repo, f.Path, head); err != nil {
+				log.Printf("[ingest] load %s@%.7s failed: %v", f.Path, head, err)
+				continue
+			}
+		}
+
+		changes, err := symbols.Compare(f.Path, before, after)
+		if err != nil {
+			log.Printf("[ingest] parse %s failed: %v", f.Path, err)
+			continue
+		}
+		out = append(out, changes...)
+	}
+	return out
+}
+
+func isGoSource(path string) bool {
+	return strings.HasSuffix(path, ".go") &&
+		!strings.HasPrefix(path, "vendor/") &&
+		!strings.Contains(path, "/vendor/")
+}
+
+// fileAt returns the file's content at ref, or nil when it does not exist
+// there.
+func fileAt(
+	ctx context.Context,
+	client *github.Client,
+	owner, repo, path, ref string,
+) ([]byte, error) {
+
+	file, _, resp, err := client.Repositories.GetContents(
+		ctx,
+		owner,
+		repo,
+		path,
+		&github.RepositoryContentGetOptions{Ref: ref},
+	)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if file == nil {
+		return nil, nil
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// BuildSymbolHistory counts how often each symbol was touched by a reverted
+// change. The original change is used when it was identified, otherwise the
+// revert itself.
+func BuildSymbolHistory(reverted []MinimalPR) []SymbolStat {
+	byKey := map[string]*SymbolStat{}
+
+	for _, pr := range reverted {
+		changes := pr.OriginalSymbols
+		if len(changes) == 0 {
+			changes = pr.Symbols
+		}
+
+		ref := revertRef(pr)
+		seen := map[string]bool{}
+		for _, c := range changes {
+			key := c.File + "\x00" + c.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			s, ok := byKey[key]
+			if !ok {
+				s = &SymbolStat{
+					File:     c.File,
+					Name:     c.Name,
+					Kind:     c.Kind,
+					Exported: c.Exported,
+				}
+				byKey[key] = s
+			}
+			s.Reverts++
+			if c.Breaking {
+				s.Breaking++
+			}
+			if ref != "" {
+				s.Refs = append(s.Refs, ref)
+			}
+		}
+	}
+
+	out := make([]SymbolStat, 0, len(byKey))
+	for _, s := range byKey {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Reverts != out[j].Reverts {
+			return out[i].Reverts > out[j].Reverts
+		}
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	_ = writeJSON("symbols.json", out)
+	return out
+}
+
+func revertRef(pr MinimalPR) string {
+	switch {
+	case pr.OriginalPRNumber != 0:
+		return "#" + strconv.Itoa(pr.OriginalPRNumber)
+	case len(pr.RevertedSHAs) > 0:
+		return shortSHA(pr.RevertedSHAs[0])
+	case pr.Number != 0:
+		return "#" + strconv.Itoa(pr.Number)
+	default:
+		return shortSHA(pr.MergeCommitSHA)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,281 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LabelRule maps labels (or issue form answers) matching Pattern to Value.
+// Value may reference capture groups, e.g. "$1" for "severity:(\w+)".
+type LabelRule struct {
+	Pattern string `json:"pattern"`
+	Value   string `json:"value"`
+
+	re *regexp.Regexp
+}
+
+// TextRule applies when an issue has no usable labels and any of Tokens
+// appears as a whole token in the title or body. A token of several words
+// matches only those words in a row.
+type TextRule struct {
+	Tokens   []string `json:"tokens"`
+	Type     string   `json:"type,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+}
+
+type IssueTaxonomy struct {
+	Types      []LabelRule       `json:"types"`
+	Severities []LabelRule       `json:"severities"`
+	Components []LabelRule       `json:"components"`
+	TextRules  []TextRule        `json:"text_rules"`
+	Aliases    map[string]string `json:"severity_aliases"`
+}
+
+type IssueClass struct {
+	Type      string `json:"type"`
+	Severity  string `json:"severity"`
+	Component string `json:"component,omitempty"`
+	Source    string `json:"source"`
+}
+
+func DefaultIssueTaxonomy() IssueTaxonomy {
+	t := IssueTaxonomy{
+		Types: []LabelRule{
+			{Pattern: `^(type[:/]\s*)?security$|^vulnerability$|^cve$`, Value: "security"},
+			{Pattern: `^(type[:/]\s*)?regression$`, Value: "regression"},
+			{Pattern: `^(type[:/]\s*|kind[:/]\s*)?(bug|defect|crash)$`, Value: "bug"},
+			{Pattern: `^(type[:/]\s*|kind[:/]\s*)?(feature|enhancement|feature request)$`, Value: "feature"},
+			{Pattern: `^(type[:/]\s*)?(docs?|documentation)$`, Value: "docs"},
+			{Pattern: `^(type[:/]\s*)?(performance|perf)$`, Value: "performance"},
+			{Pattern: `^(type[:/]\s*)?(question|support)$`, Value: "question"},
+			{Pattern: `^(type[:/]\s*|kind[:/]\s*)?(chore|task|refactor|cleanup)$`, Value: "task"},
+			{Pattern: `^(type[:/]\s*)?(ci|build|tests?|flaky)$`, Value: "test"},
+		},
+		Severities: []LabelRule{
+			{Pattern: `^(sev|severity)[:/\s-]*([0-4])$`, Value: "sev$2"},
+			{Pattern: `^(p|priority|prio)[:/\s-]*([0-4])$`, Value: "p$2"},
+			{Pattern: `^(severity|sev|priority|prio)[:/\s-]+(\w+)$`, Value: "$2"},
+			{Pattern: `^(critical|blocker|urgent|high|medium|low|minor|major|trivial)$`, Value: "$1"},
+		},
+		Components: []LabelRule{
+			{Pattern: `^(component|area|module|pkg|scope)[:/]\s*(.+)$`, Value: "$2"},
+		},
+		TextRules: []TextRule{
+			{Tokens: []string{"vulnerability", "cve", "xss", "csrf", "injection", "leak"}, Type: "security", Severity: "high"},
+			{Tokens: []string{"regression", "regressed"}, Type: "regression", Severity: "high"},
+			{Tokens: []string{"crash", "crashes", "panic", "segfault", "outage", "is down", "was down", "are down", "went down", "goes down", "site down", "service down", "server down"}, Type: "bug", Severity: "high"},
+			{Tokens: []string{"bug", "broken", "error", "fails", "failing", "exception", "incorrect", "wrong"}, Type: "bug", Severity: "medium"},
+			{Tokens: []string{"slow", "latency", "performance", "memory"}, Type: "performance", Severity: "medium"},
+			{Tokens: []string{"feature", "support", "add", "proposal", "request"}, Type: "feature", Severity: "low"},
+			{Tokens: []string{"docs", "documentation", "readme", "typo"}, Type: "docs", Severity: "low"},
+		},
+		Aliases: map[string]string{
+			"p0": "critical", "sev0": "critical", "sev1": "critical", "blocker": "critical", "urgent": "critical", "critical": "critical",
+			"p1": "high", "sev2": "high", "major": "high", "high": "high",
+			"p2": "medium", "sev3": "medium", "medium": "medium", "normal": "medium", "moderate": "medium",
+			"p3": "low", "p4": "low", "sev4": "low", "minor": "low", "trivial": "low", "low": "low",
+		},
+	}
+	t.compile()
+	return t
+}
+
+// LoadIssueTaxonomy reads a JSON taxonomy file. Sections left out of the file
+// keep their defaults.
+func LoadIssueTaxonomy(path string) (IssueTaxonomy, error) {
+	t := DefaultIssueTaxonomy()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return t, fmt.Errorf("parse taxonomy %s: %w", path, err)
+	}
+	if err := t.compile(); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// Taxonomy is used by FetchClosedIssuesRaw to classify issues.
+var Taxonomy = DefaultIssueTaxonomy()
+
+func (t *IssueTaxonomy) compile() error {
+	for _, rules := range [][]LabelRule{t.Types, t.Severities, t.Components} {
+		for i := range rules {
+			re, err := regexp.Compile("(?i)" + rules[i].Pattern)
+			if err != nil {
+				return fmt.Errorf("taxonomy pattern %q: %w", rules[i].Pattern, err)
+			}
+			rules[i].re = re
+		}
+	}
+	return nil
+}
+
+func matchRules(rules []LabelRule, values []string) string {
+	return matchRulesFunc(rules, values, func(v string) string { return v })
+}
+
+// matchRulesFunc is matchRules with a check on each match: accept returns
+// the final value, or "" to go on to the next value and rule.
+func matchRulesFunc(rules []LabelRule, values []string, accept func(string) string) string {
+	for _, r := range rules {
+		if r.re == nil {
+			continue
+		}
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if m := r.re.FindStringSubmatchIndex(v); m != nil {
+				if out := accept(strings.ToLower(string(r.re.ExpandString(nil, r.Value, v, m)))); out != "" {
+					return out
+				}
+			}
+		}
+	}
+	return ""
+}
+
+var issueFormRegex = regexp.MustCompile(`(?m)^###\s+(.+?)\s*\n+([^\n#][^\n]*)`)
+
+// issueFormFields extracts "### Heading\n\nanswer" pairs written by GitHub
+// issue forms, keyed by lower-cased heading.
+func issueFormFields(body string) map[string]string {
+	out := map[string]string{}
+	for _, m := range issueFormRegex.FindAllStringSubmatch(body, -1) {
+		answer := strings.TrimSpace(m[2])
+		if answer == "" || answer == "_No response_" {
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(m[1]))] = answer
+	}
+	return out
+}
+
+// Classify maps an issue's labels, issue form answers and, failing those, its
+// text to a normalized type, severity and component.
+func (t *IssueTaxonomy) Classify(i *Issue) IssueClass {
+	labels := make([]string, 0, len(i.Labels))
+	for _, l := range i.Labels {
+		labels = append(labels, l.Name)
+	}
+
+	form := issueFormFields(i.Body)
+	var formType, formSeverity, formComponent []string
+	headings := make([]string, 0, len(form))
+	for heading := range form {
+		headings = append(headings, heading)
+	}
+	sort.Strings(headings)
+
+	for _, heading := range headings {
+		answer := form[heading]
+		switch {
+		case strings.Contains(heading, "type") || strings.Contains(heading, "kind"):
+			formType = append(formType, answer)
+		case strings.Contains(heading, "severity") || strings.Contains(heading, "priority") || strings.Contains(heading, "impact"):
+			formSeverity = append(formSeverity, answer)
+		case strings.Contains(heading, "component") || strings.Contains(heading, "area") || strings.Contains(heading, "module"):
+			formComponent = append(formComponent, answer)
+		}
+	}
+
+	var c IssueClass
+
+	if c.Type = matchRules(t.Types, labels); c.Type != "" {
+		c.Source = "labels"
+	} else if c.Type = matchRules(t.Types, formType); c.Type != "" {
+		c.Source = "issue_form"
+	}
+	// A severity match that has no alias gives way to the next rule.
+	c.Severity = matchRulesFunc(t.Severities, labels, t.normalizeSeverity)
+	if c.Severity == "" {
+		c.Severity = matchRulesFunc(t.Severities, formSeverity, t.normalizeSeverity)
+		if c.Severity == "" && len(formSeverity) > 0 {
+			c.Severity = t.normalizeSeverity(strings.ToLower(strings.Fields(formSeverity[0])[0]))
+		}
+	}
+	c.Component = matchRules(t.Components, labels)
+	if c.Component == "" && len(formComponent) > 0 {
+		c.Component = strings.ToLower(formComponent[0])
+	}
+
+	if c.Type == "" || c.Severity == "" {
+		tokens := newTokenSet(i.Title + " " + i.Body)
+		for _, r := range t.TextRules {
+			if !r.matches(tokens) {
+				continue
+			}
+			if c.Type == "" && r.Type != "" {
+				c.Type = r.Type
+				c.Source = "text"
+			}
+			if c.Severity == "" && r.Severity != "" {
+				c.Severity = r.Severity
+			}
+			if c.Type != "" && c.Severity != "" {
+				break
+			}
+		}
+	}
+
+	if c.Type == "" {
+		c.Type = "other"
+		c.Source = "default"
+	}
+	if c.Severity == "" {
+		c.Severity = "unknown"
+	}
+	return c
+}
+
+func (t *IssueTaxonomy) normalizeSeverity(s string) string {
+	if s == "" {
+		return ""
+	}
+	if v, ok := t.Aliases[s]; ok {
+		return v
+	}
+	return ""
+}
+
+func (r TextRule) matches(tokens tokenSet) bool {
+	for _, tok := range r.Tokens {
+		if tokens.has(tok) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenSet holds text as lower-cased word tokens so that rules match whole
+// words only ("ci" does not match "decision"). The words are also kept in
+// order, space-separated, for rules of several words.
+type tokenSet struct {
+	words  map[string]bool
+	joined string
+}
+
+func newTokenSet(text string) tokenSet {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	t := tokenSet{words: map[string]bool{}, joined: " " + strings.Join(words, " ") + " "}
+	for _, w := range words {
+		t.words[w] = true
+	}
+	return t
+}
+
+func (t tokenSet) has(tok string) bool {
+	tok = strings.ToLower(tok)
+	if strings.Contains(tok, " ") {
+		return strings.Contains(t.joined, " "+strings.Join(strings.Fields(tok), " ")+" ")
+	}
+	return t.words[tok]
+}
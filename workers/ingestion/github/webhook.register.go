@@ -8,9 +8,7 @@ import (
 	"github.com/google/go-github/v61/github"
 )
 
-func SetupRepoWebhook(client *github.Client, owner, repo, targetURL, secret string) error {
-	ctx := context.Background()
-
+func SetupRepoWebhook(ctx context.Context, client *github.Client, owner, repo, targetURL, secret string) error {
 	config := &github.HookConfig{
 		URL:         github.String(targetURL),
 		ContentType: github.String("json"),
@@ -3,6 +3,7 @@ module codrel-sentinel/workers/ingestion-worker
 go 1.24.0
 
 require (
+	codrel-sentinel/workers/shared v0.0.0
 	github.com/confluentinc/confluent-kafka-go v1.9.2
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/go-github/v61 v61.0.0
@@ -11,4 +12,9 @@ require (
 	golang.org/x/time v0.14.0
 )
 
-require github.com/google/go-querystring v1.1.0 // indirect
+require (
+	github.com/google/go-querystring v1.1.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace codrel-sentinel/workers/shared => ../shared
@@ -7,22 +7,34 @@ import (
 	"os"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
-	"github.com/joho/godotenv"
 )
 
-func NewConsumer() (*kafka.Consumer, error) {
-	godotenv.Load()
-	servers := os.Getenv("KAFKA_BROKER")
-	if servers == "" {
-		servers = config.BootstrapServers
-	}
+func NewConsumer(cfg *config.Config) (*kafka.Consumer, error) {
 	return kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": servers,
-		"group.id":          config.ConsumerGroup,
+		"bootstrap.servers": cfg.Kafka.Brokers,
+		"group.id":          cfg.Ingestion.ConsumerGroup,
 		"auto.offset.reset": "earliest",
 	})
 }
 
+// NewControlConsumer reads the control topic. Every worker process needs to
+// see every control message, since any of them may be running the job, so
+// each gets its own consumer group and starts from the latest offset.
+func NewControlConsumer(cfg *config.Config) (*kafka.Consumer, error) {
+	host, _ := os.Hostname()
+	return kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": cfg.Kafka.Brokers,
+		"group.id":          cfg.Ingestion.ConsumerGroup + "-control-" + host,
+		"auto.offset.reset": "latest",
+	})
+}
+
+func ReadControlMessage(msg *kafka.Message) (*model.ControlMessage, error) {
+	var m model.ControlMessage
+	err := json.Unmarshal(msg.Value, &m)
+	return &m, err
+}
+
 func ReadIngestRequest(msg *kafka.Message) (*model.IngestRequest, error) {
 	var req model.IngestRequest
 	err := json.Unmarshal(msg.Value, &req)
@@ -1,12 +1,40 @@
 package kafka
 
 import (
+	"encoding/json"
+
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"codrel-sentinel/workers/ingestion-worker/config"
+	"codrel-sentinel/workers/ingestion-worker/model"
 )
 
-func NewProducer() (*kafka.Producer, error) {
+func NewProducer(cfg *config.Config) (*kafka.Producer, error) {
 	return kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": config.BootstrapServers,
+		"bootstrap.servers": cfg.Kafka.Brokers,
 	})
 }
+
+// PublishRequest queues an ingest request on topic, keyed by repo, and waits
+// for the broker to accept it.
+func PublishRequest(producer *kafka.Producer, topic string, req model.IngestRequest) error {
+	value, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	delivery := make(chan kafka.Event, 1)
+	err = producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   []byte(req.Repo),
+		Value: value,
+	}, delivery)
+	if err != nil {
+		return err
+	}
+
+	m := (<-delivery).(*kafka.Message)
+	return m.TopicPartition.Error
+}
@@ -0,0 +1,265 @@
+package keywords
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	pathRegex = regexp.MustCompile(`(?:[\w.@-]+/)*[\w.@-]+\.[A-Za-z][A-Za-z0-9]{0,5}\b`)
+	codeRegex = regexp.MustCompile(`\b(?:[A-Z]{1,5}\d{2,6}|ERR_[A-Z0-9_]+|` + errnoPattern + `|0x[0-9a-fA-F]{4,})\b`)
+)
+
+// errnoPattern lists the errno names that show up in CI and issue text.
+// Spelling them out keeps ordinary capitalised words such as ERROR or EMPTY
+// from being taken for codes.
+const errnoPattern = `E(?:ACCES|ADDRINUSE|ADDRNOTAVAIL|AGAIN|AI_AGAIN|BADF|BUSY|CONNABORTED|CONNREFUSED|CONNRESET|EXIST|HOSTUNREACH|INVAL|ISDIR|LOOP|MFILE|NAMETOOLONG|NETUNREACH|NOENT|NOMEM|NOSPC|NOTDIR|NOTEMPTY|NOTFOUND|NOTSUP|PERM|PIPE|PROTO|ROFS|TIMEDOUT|XDEV)`
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "or": true, "to": true, "a": true, "an": true,
+	"of": true, "in": true, "on": true, "for": true, "with": true, "is": true,
+	"it": true, "be": true, "as": true, "at": true, "by": true, "this": true,
+	"that": true, "from": true, "are": true, "was": true, "were": true, "not": true,
+	"but": true, "if": true, "we": true, "i": true, "you": true, "can": true,
+	"when": true, "should": true, "would": true, "will": true, "has": true,
+	"have": true, "had": true, "do": true, "does": true, "there": true,
+	"into": true, "so": true, "no": true, "any": true, "all": true, "then": true,
+	"than": true, "also": true, "just": true, "some": true, "what": true,
+	"which": true, "how": true, "our": true, "its": true, "get": true,
+	"http": true, "https": true, "www": true, "com": true,
+}
+
+// Tokenize splits text into terms. File paths and error codes are kept
+// whole, identifiers are kept whole and also split on camelCase and
+// snake_case boundaries, and non-ASCII words are preserved.
+func Tokenize(text string) []string {
+	var out []string
+
+	for _, m := range pathRegex.FindAllString(text, -1) {
+		if strings.Contains(m, "/") || isSourceFile(m) {
+			out = append(out, m)
+		}
+	}
+	for _, m := range codeRegex.FindAllString(text, -1) {
+		out = append(out, m)
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+	})
+
+	for _, w := range words {
+		parts := splitIdentifier(w)
+		if len(parts) > 1 {
+			out = appendTerm(out, strings.ToLower(strings.Trim(w, "_")))
+		}
+		for _, p := range parts {
+			out = appendTerm(out, strings.ToLower(p))
+		}
+	}
+
+	return out
+}
+
+func appendTerm(out []string, t string) []string {
+	if stopWords[t] {
+		return out
+	}
+
+	n := 0
+	digits := true
+	for _, r := range t {
+		n++
+		if !unicode.IsDigit(r) {
+			digits = false
+		}
+	}
+
+	switch {
+	case digits && n < 3:
+		return out
+	case n < 2:
+		return out
+	case n < 3 && isASCII(t):
+		return out
+	}
+	return append(out, t)
+}
+
+// splitIdentifier splits on underscores and on lower→upper, letter→digit and
+// acronym boundaries ("parseHTTPResponse2" → parse, HTTP, Response, 2).
+func splitIdentifier(w string) []string {
+	var parts []string
+	for _, seg := range strings.Split(w, "_") {
+		if seg == "" {
+			continue
+		}
+		rs := []rune(seg)
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			prev, cur := rs[i-1], rs[i]
+			boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+				unicode.IsLetter(prev) && unicode.IsDigit(cur) ||
+				unicode.IsDigit(prev) && unicode.IsLetter(cur) ||
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if boundary {
+				parts = append(parts, string(rs[start:i]))
+				start = i
+			}
+		}
+		parts = append(parts, string(rs[start:]))
+	}
+	return parts
+}
+
+func isSourceFile(s string) bool {
+	i := strings.LastIndex(s, ".")
+	if i <= 0 {
+		return false
+	}
+	switch strings.ToLower(s[i+1:]) {
+	case "go", "ts", "tsx", "js", "jsx", "mjs", "py", "rb", "rs", "java", "kt",
+		"c", "cc", "cpp", "h", "hpp", "cs", "php", "swift", "scala",
+		"json", "yaml", "yml", "toml", "sql", "proto", "sh":
+		return true
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// Corpus holds document frequencies for one repository's issues, PRs and
+// crashes so terms common across the repo rank lower than distinctive ones.
+type Corpus struct {
+	docs int
+	df   map[string]int
+}
+
+func NewCorpus() *Corpus {
+	return &Corpus{df: map[string]int{}}
+}
+
+// MaxStoredTerms caps the terms kept per repo between jobs.
+const MaxStoredTerms = 20000
+
+// Counts is the stored form of a corpus, so document frequencies from past
+// jobs can be merged into the next one.
+type Counts struct {
+	Docs int            `json:"docs"`
+	DF   map[string]int `json:"df"`
+}
+
+// Merge adds stored counts to the corpus.
+func (c *Corpus) Merge(counts Counts) {
+	c.docs += counts.Docs
+	for k, n := range counts.DF {
+		c.df[k] += n
+	}
+}
+
+// Counts returns the corpus's document frequencies, keeping at most limit
+// terms (0 keeps all). The most common terms are kept, since they are the
+// ones that change a ranking; a dropped term just looks rare.
+func (c *Corpus) Counts(limit int) Counts {
+	keys := make([]string, 0, len(c.df))
+	for k := range c.df {
+		keys = append(keys, k)
+	}
+	if limit > 0 && len(keys) > limit {
+		sort.Slice(keys, func(i, j int) bool {
+			if c.df[keys[i]] != c.df[keys[j]] {
+				return c.df[keys[i]] > c.df[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		keys = keys[:limit]
+	}
+
+	df := make(map[string]int, len(keys))
+	for _, k := range keys {
+		df[k] = c.df[k]
+	}
+	return Counts{Docs: c.docs, DF: df}
+}
+
+func (c *Corpus) Add(text string) {
+	c.docs++
+	seen := map[string]bool{}
+	for _, t := range Tokenize(text) {
+		k := strings.ToLower(t)
+		if !seen[k] {
+			seen[k] = true
+			c.df[k]++
+		}
+	}
+}
+
+// Top returns up to n terms of text ranked by TF-IDF against the corpus. A nil
+// corpus ranks by term frequency alone. Ties keep first-occurrence order.
+func (c *Corpus) Top(text string, n int) []string {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	type term struct {
+		text  string
+		count int
+		first int
+		score float64
+	}
+
+	byKey := map[string]*term{}
+	var terms []*term
+	for i, t := range tokens {
+		k := strings.ToLower(t)
+		if tm, ok := byKey[k]; ok {
+			tm.count++
+			continue
+		}
+		tm := &term{text: t, count: 1, first: i}
+		byKey[k] = tm
+		terms = append(terms, tm)
+	}
+
+	for k, tm := range byKey {
+		tf := float64(tm.count) / float64(len(tokens))
+		idf := 1.0
+		if c != nil {
+			idf = math.Log(float64(1+c.docs)/float64(1+c.df[k])) + 1
+		}
+		tm.score = tf * idf
+	}
+
+	sort.SliceStable(terms, func(i, j int) bool {
+		if terms[i].score != terms[j].score {
+			return terms[i].score > terms[j].score
+		}
+		return terms[i].first < terms[j].first
+	})
+
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	out := make([]string, 0, len(terms))
+	for _, tm := range terms {
+		out = append(out, tm.text)
+	}
+	return out
+}
+
+// Extract ranks terms of a single text without a corpus.
+func Extract(text string, n int) []string {
+	var c *Corpus
+	return c.Top(text, n)
+}
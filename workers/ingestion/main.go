@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -11,59 +13,161 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/joho/godotenv"
 	"golang.org/x/time/rate"
 
 	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
 
+	"codrel-sentinel/workers/ingestion-worker/auth"
 	"codrel-sentinel/workers/ingestion-worker/config"
+	"codrel-sentinel/workers/ingestion-worker/control"
 	"codrel-sentinel/workers/ingestion-worker/db"
+	"codrel-sentinel/workers/ingestion-worker/dispatch"
 	"codrel-sentinel/workers/ingestion-worker/github"
 	"codrel-sentinel/workers/ingestion-worker/kafka"
+	"codrel-sentinel/workers/ingestion-worker/keywords"
 	"codrel-sentinel/workers/ingestion-worker/model"
+	"codrel-sentinel/workers/ingestion-worker/progress"
+	"codrel-sentinel/workers/ingestion-worker/redact"
+	"codrel-sentinel/workers/ingestion-worker/scheduler"
+	sharedconfig "codrel-sentinel/workers/shared/config"
+	"codrel-sentinel/workers/shared/depgraph"
 )
 
 const (
-	parallelism = 2
-	jobBuffer   = 100
+	statusPollInterval = 5 * time.Second
+	repoBusyRetry      = time.Minute
 )
 
 var githubLimiter = rate.NewLimiter(2, 4)
 
-var outTopic = config.AnalysisTopic
+var registry = control.NewRegistry()
+
+var githubApp *auth.GitHubApp
+
+var cfg *config.Config
+
+var outTopic string
+
+var fatalStages progress.Policy
+
+var redactor *redact.Redactor
 
 type AnalysisEnvelope struct {
-	Repo string `json:"repo"`
+	Repo  string `json:"repo"`
+	JobID string `json:"job_id"`
 
 	WorkflowCrash *model.WorkflowCrashPayload `json:"workflow_crash"`
 	Bug           *model.BugPayload           `json:"bug"`
 	Rule          *model.ArchPayload          `json:"rule"`
+	Security      *model.SecurityPayload      `json:"security,omitempty"`
 
 	RevertedPRs []model.RevertedPRPayload `json:"reverted_prs"`
 	RejectedPRs []model.RejectedPRPayload `json:"rejected_prs"`
+	MergedPRs   []model.MergedPRPayload   `json:"merged_prs"`
+
+	SymbolHistory []github.SymbolStat   `json:"symbol_history,omitempty"`
+	Hotspots      []github.FileHotspot  `json:"hotspots,omitempty"`
+	CoChanges     []github.FileCoupling `json:"co_changes,omitempty"`
+
+	Releases *github.ReleaseHistory `json:"releases,omitempty"`
+
+	// Backfill is set on envelopes that carry one slice of a backfill.
+	Backfill *BackfillChunk `json:"backfill,omitempty"`
+
+	// Stages says, per stage, whether its section above is complete. An
+	// empty section next to a failed or skipped stage means "unknown", not
+	// "nothing found".
+	Stages map[string]progress.Result `json:"stages"`
 }
 
 func main() {
-	db.InitDB()
-	consumer, err := kafka.NewConsumer()
+	_ = godotenv.Load()
+
+	c, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg = c
+	log.Printf("ingestion worker config:\n%s", sharedconfig.Dump(cfg))
+
+	outTopic = cfg.Ingestion.AnalysisTopic
+	fatalStages = progress.ParsePolicy(cfg.Ingestion.FatalStages)
+	github.MaxMergedSymbolPRs = cfg.Ingestion.MergedSymbolPRs
+
+	db.InitDB(cfg.Database.URL)
+
+	if path := cfg.Ingestion.IssueTaxonomyPath; path != "" {
+		taxonomy, err := github.LoadIssueTaxonomy(path)
+		if err != nil {
+			log.Fatal("failed to load issue taxonomy:", err)
+		}
+		github.Taxonomy = taxonomy
+	}
+
+	var extraRules []redact.Rule
+	if path := cfg.Ingestion.RedactionRulesPath; path != "" {
+		rules, err := redact.LoadRules(path)
+		if err != nil {
+			log.Fatal("failed to load redaction rules:", err)
+		}
+		extraRules = rules
+	}
+	r, err := redact.New(extraRules)
+	if err != nil {
+		log.Fatal("failed to build redactor:", err)
+	}
+	redactor = r
+
+	if cfg.GitHubApp.Enabled() {
+		app, err := auth.NewGitHubApp(cfg.GitHubApp.AppID, cfg.GitHubApp.PrivateKeyPath)
+		if err != nil {
+			log.Fatal("failed to load GitHub App key:", err)
+		}
+		githubApp = app
+	}
+
+	consumer, err := kafka.NewConsumer(cfg)
 	if err != nil {
 		panic(err)
 	}
 	defer consumer.Close()
 
-	producer, err := kafka.NewProducer()
+	producer, err := kafka.NewProducer(cfg)
 	if err != nil {
 		panic(err)
 	}
 	defer producer.Close()
 
-	if err := consumer.Subscribe(config.RequestTopic, nil); err != nil {
+	if err := consumer.Subscribe(cfg.Ingestion.RequestTopic, nil); err != nil {
+		panic(err)
+	}
+
+	controlConsumer, err := kafka.NewControlConsumer(cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer controlConsumer.Close()
+
+	if err := controlConsumer.Subscribe(cfg.Ingestion.ControlTopic, nil); err != nil {
 		panic(err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	jobs := make(chan *ckafka.Message, jobBuffer)
+	go registry.Consume(ctx, controlConsumer)
+
+	if githubApp != nil && cfg.Ingestion.SyncScheduler {
+		schedCfg := scheduler.DefaultConfig()
+		schedCfg.RequestTopic = cfg.Ingestion.RequestTopic
+		schedCfg.Interval = cfg.Ingestion.SyncInterval
+		go scheduler.New(schedCfg, producer).Run(ctx)
+	} else {
+		log.Println("re-sync scheduler disabled")
+	}
+
+	dispatcher := dispatch.New(cfg.Ingestion.JobBuffer)
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
@@ -71,26 +175,44 @@ func main() {
 	log.Println("ingestion worker started")
 
 	go func() {
-		defer close(jobs)
 		for {
 			select {
 			case <-ctx.Done():
 				log.Println("poll loop stopping")
 				return
 			default:
+				if dispatcher.Full() {
+					time.Sleep(500 * time.Millisecond)
+					continue
+				}
 				msg, err := consumer.ReadMessage(500 * time.Millisecond)
-				if err == nil {
-					jobs <- msg
+				if err != nil {
+					continue
+				}
+				req, err := kafka.ReadIngestRequest(msg)
+				if err != nil {
+					log.Println("invalid request:", err)
+					continue
+				}
+				owner, _, _ := strings.Cut(req.Repo, "/")
+				queued := dispatcher.Push(&dispatch.Item{
+					Tenant: strings.ToLower(owner),
+					Repo:   req.Repo,
+					Kind:   req.Type,
+					Value:  req,
+				})
+				if !queued {
+					log.Printf("dropping duplicate %s request for %s", req.Type, req.Repo)
 				}
 			}
 		}
 	}()
 
 	var wg sync.WaitGroup
-	wg.Add(parallelism)
+	wg.Add(cfg.Ingestion.Parallelism)
 
-	for i := 0; i < parallelism; i++ {
-		go worker(ctx, &wg, jobs, producer)
+	for i := 0; i < cfg.Ingestion.Parallelism; i++ {
+		go worker(ctx, &wg, dispatcher, producer)
 	}
 
 	<-sig
@@ -98,57 +220,126 @@ func main() {
 	cancel()
 
 	wg.Wait()
+	orgJobs.Wait()
 	producer.Flush(5000)
 	log.Println("all workers stopped")
 }
 
+// jobClass groups request types that do the same work, so one can stand in
+// for another that finds the repo busy.
+func jobClass(kind string) string {
+	switch kind {
+	case "sync", "connection":
+		return "ingest"
+	}
+	return kind
+}
+
+// sameClassRunning reports whether another worker holds the class lock at
+// key. When unsure it says no, so the request is retried rather than lost.
+func sameClassRunning(ctx context.Context, key int64) bool {
+	conn, locked, err := db.TryAdvisoryLock(ctx, key)
+	if err != nil {
+		return false
+	}
+	if locked {
+		db.ReleaseAdvisoryLock(conn, key)
+		return false
+	}
+	return true
+}
+
 func worker(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	jobs <-chan *ckafka.Message,
+	dispatcher *dispatch.Dispatcher,
 	producer *ckafka.Producer,
 ) {
 	defer wg.Done()
 
 	for {
-		select {
-		case <-ctx.Done():
+		it, ok := dispatcher.Next(ctx)
+		if !ok {
 			return
-		case msg, ok := <-jobs:
-			if !ok {
-				return
-			}
-			processMessage(ctx, msg, producer)
 		}
+		if processMessage(ctx, it.Value.(*model.IngestRequest), producer) {
+			log.Printf("%s is busy on another worker, retrying %s later", it.Repo, it.Kind)
+			dispatcher.Retry(it, repoBusyRetry)
+			continue
+		}
+		dispatcher.Done(it)
 	}
 }
+
+// processMessage runs one request. It reports busy when another worker holds
+// the repo and the request should be tried again later.
 func processMessage(
 	ctx context.Context,
-	msg *ckafka.Message,
+	req *model.IngestRequest,
 	producer *ckafka.Producer,
-) {
-	req, err := kafka.ReadIngestRequest(msg)
-	if err != nil {
-		log.Println("invalid request:", err)
+) (busy bool) {
+	// An org request names an account, not a repo, and waits on the repos
+	// it queues, so it runs outside the worker pool.
+	if req.Type == "org" {
+		log.Printf("processing org: %s", req.Repo)
+		orgJobs.Add(1)
+		go func() {
+			defer orgJobs.Done()
+			runOrg(ctx, req, producer)
+		}()
 		return
 	}
 
 	parts := strings.Split(req.Repo, "/")
 	if len(parts) != 2 {
-		db.UpdateStatus(req.Repo, "FAILED")
+		db.MarkFailed(req.Repo, "invalid repo name: "+req.Repo)
 		log.Println("invalid repo:", req.Repo)
-		return
+		return false
+	}
+
+	// The dispatcher keeps one job per repo on this worker; the advisory
+	// lock does the same across workers. Each job also holds a lock for its
+	// class, so a request that finds the repo busy can tell what holds it:
+	// a sync or connection behind another full ingestion is dropped, as the
+	// running job covers it; anything else waits.
+	lockKey := db.RepoLockKey(req.Repo)
+	classKey := db.JobLockKey(req.Repo, jobClass(req.Type))
+	lock, locked, err := db.TryAdvisoryLock(ctx, lockKey)
+	if err != nil {
+		log.Printf("repo lock for %s failed: %v", req.Repo, err)
+		return true
+	}
+	if !locked {
+		if req.Type != "backfill" && sameClassRunning(ctx, classKey) {
+			log.Printf("%s already has a %s job running, dropping %s", req.Repo, jobClass(req.Type), req.Type)
+			return false
+		}
+		return true
+	}
+	if ok, err := db.TryAdvisoryLockOn(ctx, lock, classKey); err != nil || !ok {
+		log.Printf("class lock for %s not taken: %v", req.Repo, err)
+	}
+	defer db.ReleaseAdvisoryLock(lock, lockKey, classKey)
+
+	// Scheduled syncs carry no token; mint one from the GitHub App.
+	if req.AccessToken == "" {
+		if githubApp == nil {
+			log.Printf("no access token for %s and no GitHub App configured", req.Repo)
+			return
+		}
+		token, err := githubApp.GetInstallationToken(parts[0], parts[1])
+		if err != nil {
+			log.Printf("installation token for %s failed: %v", req.Repo, err)
+			return
+		}
+		req.AccessToken = token
 	}
 
 	client := github.NewClient(req.AccessToken)
 
 	switch req.Type {
-	case "sync":
-		log.Println("syning repo:", req.Repo)
-		log.Printf("[sync] received sync request for %s - skipping for now", req.Repo)
-		return
-	case "connection":
-		log.Println("processing repo:", req.Repo)
+	case "sync", "connection":
+		log.Printf("processing repo: %s (%s)", req.Repo, req.Type)
 
 		// webhookURL := os.Getenv("BACKEND_WEBHOOK_URL")
 		// webhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET")
@@ -161,64 +352,309 @@ func processMessage(
 		// 	}
 		// }
 
-		db.UpdateStatus(req.Repo, "FETCHING")
+		if status, err := db.GetStatus(req.Repo); err == nil && status == progress.StatusPaused {
+			log.Printf("%s is paused, skipping", req.Repo)
+			return
+		}
+
+		jobCtx, release := registry.Start(ctx, req.Repo)
+		defer release()
+		go registry.WatchStatus(jobCtx, req.Repo, statusPollInterval)
+
+		job := progress.NewJob(req.Repo, fatalStages)
+		cp := loadCheckpoint(req.Repo)
+		job.SetStatus(progress.StatusFetching)
 		envelope := AnalysisEnvelope{
-			Repo: req.Repo,
+			Repo:  req.Repo,
+			JobID: job.ID,
 		}
 
 		var stages sync.WaitGroup
 		var mu sync.Mutex
+		var graph *depgraph.Graph
+		var history []github.HistoryCommit
+		var direct []github.MinimalPR
+		prBuckets := &github.PRBuckets{}
+
+		// stopped ends a cancelled job: it lets running stages unwind and
+		// keeps what finished in a checkpoint. A paused repo stays PAUSED
+		// until resumed; on shutdown the request is queued again so another
+		// worker picks it up; a cancelled job leaves the repo FAILED.
+		stopped := func() bool {
+			if jobCtx.Err() == nil {
+				return false
+			}
+			stages.Wait()
 
-		stages.Add(3)
-
-		go func() {
-			defer stages.Done()
 			mu.Lock()
-			envelope.WorkflowCrash = ProcessWorkflowCrash(req, req.AccessToken, parts[0], parts[1])
+			next := &checkpoint{
+				JobID:         job.ID,
+				WorkflowCrash: envelope.WorkflowCrash,
+				Bug:           envelope.Bug,
+				Rule:          envelope.Rule,
+				Security:      envelope.Security,
+				History:       history,
+				PRBuckets:     prBuckets,
+				DirectReverts: direct,
+				Releases:      envelope.Releases,
+			}
 			mu.Unlock()
-		}()
 
-		go func() {
-			defer stages.Done()
-			mu.Lock()
-			envelope.Bug = ProcessBug(req, req.AccessToken, parts[0], parts[1])
-			mu.Unlock()
-		}()
+			cause := control.Cause(jobCtx)
+			saveCheckpoint(req.Repo, next, job.Results(), cause)
+			switch {
+			case errors.Is(cause, control.ErrPaused):
+				job.SetStatus(progress.StatusPaused)
+			case errors.Is(cause, control.ErrShutdown):
+				if err := kafka.PublishRequest(producer, cfg.Ingestion.RequestTopic, *req); err != nil {
+					log.Printf("requeue %s for %s failed: %v", req.Type, req.Repo, err)
+					return true
+				}
+				job.SetStatus(progress.StatusQueued)
+			default:
+				job.Fail("cancelled", cause)
+			}
+			return true
+		}
 
+		// aborted ends a job whose fatal stage failed: it marks the repo
+		// FAILED, then cancels and waits for the stages still running so
+		// none outlives the job or its repo lock.
+		aborted := func() bool {
+			if !job.Abort() {
+				return false
+			}
+			stage, err := job.Fatal()
+			registry.Cancel(req.Repo, fmt.Errorf("%s failed: %w", stage, err))
+			stages.Wait()
+			return true
+		}
+
+		if !restore(cp, job, "crashes", cp.WorkflowCrash, &envelope.WorkflowCrash) {
+			stages.Add(1)
+			go func() {
+				defer stages.Done()
+				stage := job.Stage("crashes")
+				stage.Cover(github.WindowStart(), github.MaxWorkflowFailures)
+				payload, err := ProcessWorkflowCrash(jobCtx, req, req.AccessToken, parts[0], parts[1])
+				stage.Done(len(payload.Crash), err)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				envelope.WorkflowCrash = payload
+				mu.Unlock()
+			}()
+		}
+
+		if !restore(cp, job, "bugs", cp.Bug, &envelope.Bug) {
+			stages.Add(1)
+			go func() {
+				defer stages.Done()
+				stage := job.Stage("bugs")
+				stage.Cover(time.Time{}, github.MaxClosedIssues)
+				payload, err := ProcessBug(jobCtx, req, req.AccessToken, parts[0], parts[1])
+				stage.Done(len(payload.Issues), err)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				envelope.Bug = payload
+				mu.Unlock()
+			}()
+		}
+
+		if !restore(cp, job, "architecture", cp.Rule, &envelope.Rule) {
+			stages.Add(1)
+			go func() {
+				defer stages.Done()
+				stage := job.Stage("architecture")
+				files, err := github.FetchRepoArchitecture(jobCtx, client, parts[0], parts[1])
+				stage.Done(len(files), err)
+				if err != nil {
+					log.Println("fetch repo architecture failed:", err)
+					return
+				}
+				mu.Lock()
+				envelope.Rule = &model.ArchPayload{Files: files}
+				mu.Unlock()
+			}()
+		}
+
+		if !restore(cp, job, "security", cp.Security, &envelope.Security) {
+			stages.Add(1)
+			go func() {
+				defer stages.Done()
+				stage := job.Stage("security")
+				alerts, err := github.FetchSecurityAlerts(jobCtx, client, parts[0], parts[1])
+
+				var unavailable *github.AlertsUnavailableError
+				switch {
+				case errors.As(err, &unavailable) && unavailable.All():
+					stage.Skip(unavailable.Error())
+					return
+				case errors.As(err, &unavailable):
+					stage.Warn("%v", unavailable)
+				case err != nil:
+					stage.Done(0, err)
+					log.Println("security alerts failed:", err)
+					return
+				}
+				stage.Done(len(alerts), nil)
+				mu.Lock()
+				envelope.Security = &model.SecurityPayload{Alerts: alerts}
+				mu.Unlock()
+			}()
+		}
+
+		stages.Add(1)
 		go func() {
 			defer stages.Done()
-			files, err := github.FetchRepoArchitecture(client, parts[0], parts[1])
+			stage := job.Stage("import_graph")
+			g, err := github.FetchImportGraph(jobCtx, client, parts[0], parts[1])
 			if err != nil {
-				log.Println("fetch repo architecture failed:", err)
+				stage.Done(0, err)
+				log.Println("import graph failed:", err)
 				return
 			}
+			stage.Done(g.Size(), nil)
 			mu.Lock()
-			envelope.Rule = &model.ArchPayload{Files: files}
+			graph = g
 			mu.Unlock()
 		}()
 
-		if err := githubLimiter.Wait(ctx); err != nil {
-			db.UpdateStatus(req.Repo, "FAILED")
-			log.Println("rate limiter cancelled")
-			return
+		if !restore(cp, job, "history", cp.History, &history) {
+			stages.Add(1)
+			go func() {
+				defer stages.Done()
+				stage := job.Stage("history")
+				stage.Cover(github.WindowStart(), github.MaxHistoryCommits)
+				commits, err := github.FetchCommitHistory(jobCtx, client, parts[0], parts[1])
+				stage.Done(len(commits), err)
+				if err != nil {
+					log.Println("commit history failed:", err)
+					return
+				}
+				mu.Lock()
+				history = commits
+				mu.Unlock()
+			}()
 		}
 
-		prBuckets, err := github.FetchClosedPRBuckets(
-			client,
-			parts[0],
-			parts[1],
-		)
-		if err != nil {
-			db.UpdateStatus(req.Repo, "FAILED")
-			log.Println("github fetch failed:", err)
+		if !restore(cp, job, "pr_buckets", cp.PRBuckets, &prBuckets) {
+			prStage := job.Stage("pr_buckets")
+			prStage.Cover(github.WindowStart(), 0)
+			err := githubLimiter.Wait(jobCtx)
+			if err == nil {
+				var buckets *github.PRBuckets
+				buckets, err = github.FetchClosedPRBuckets(
+					jobCtx,
+					client,
+					parts[0],
+					parts[1],
+				)
+				if err == nil {
+					mu.Lock()
+					prBuckets = buckets
+					mu.Unlock()
+				}
+			}
+			if err != nil {
+				log.Println("github fetch failed:", err)
+			}
+			prStage.Done(len(prBuckets.Reverted)+len(prBuckets.Rejected)+len(prBuckets.Merged), err)
+		}
+		if stopped() || aborted() {
 			return
 		}
+		if prBuckets == nil {
+			prBuckets = &github.PRBuckets{}
+		}
 
 		reverted := prBuckets.Reverted
 		rejected := prBuckets.Rejected
+		merged := prBuckets.Merged
+
+		if !restore(cp, job, "direct_reverts", cp.DirectReverts, &direct) {
+			revertStage := job.Stage("direct_reverts")
+			revertStage.Cover(github.WindowStart(), 0)
+			found, err := github.FetchDirectReverts(jobCtx, client, parts[0], parts[1])
+			if err != nil {
+				log.Println("direct revert scan failed:", err)
+			}
+			revertStage.Done(len(found), err)
+			mu.Lock()
+			direct = found
+			mu.Unlock()
+		}
+		reverted = append(reverted, direct...)
+
+		if restore(cp, job, "releases", cp.Releases, &envelope.Releases) {
+			github.AnnotateReleases(envelope.Releases, reverted, merged)
+		} else {
+			releaseStage := job.Stage("releases")
+			releases, err := github.FetchReleaseHistory(jobCtx, client, parts[0], parts[1], reverted, merged)
+			if err != nil {
+				releaseStage.Done(0, err)
+				log.Println("release history failed:", err)
+			} else {
+				releaseStage.Done(len(releases.Deployments)+len(releases.Releases), nil)
+			}
+			mu.Lock()
+			envelope.Releases = releases
+			mu.Unlock()
+		}
+
+		stages.Wait()
+		if stopped() || aborted() {
+			return
+		}
+
+		// Annotating is still the worker's job, so the repo stays FETCHING
+		// until the envelope is handed off; ANALYZING belongs to the analyzer.
+		annotateStage := job.Stage("annotate")
+
+		var (
+			issues  []github.Issue
+			crashes []github.WorkflowCrash
+		)
+		if envelope.Bug != nil {
+			issues = envelope.Bug.Issues
+		}
+		if envelope.WorkflowCrash != nil {
+			crashes = envelope.WorkflowCrash.Crash
+		}
+		stored := loadKeywordCounts(req.Repo)
+		counts := github.AnnotateKeywords(stored.past(keywordsBackfill), issues, crashes, reverted, rejected, merged)
+		if counts.Docs > 0 {
+			if err := saveKeywordCounts(req.Repo, keywordsSync, counts); err != nil {
+				annotateStage.Warn("save keyword counts failed: %v", err)
+			}
+		}
+		if graph == nil {
+			annotateStage.Warn("no import graph, blast radius skipped")
+		}
+		github.AnnotateBlastRadius(graph, crashes, reverted, rejected, merged)
+		envelope.SymbolHistory = github.BuildSymbolHistory(reverted)
+		envelope.Hotspots = github.ComputeHotspots(history)
+		envelope.CoChanges = github.ComputeCoChanges(history, merged)
+		// Saving replaces the stored pairs, so a failed history fetch or an
+		// empty result must not wipe them.
+		switch res := job.Results()["history"]; {
+		case res.Status != progress.ResultOK && res.Status != progress.ResultPartial:
+			annotateStage.Warn("history stage %s, stored co-changes kept", res.Status)
+		case len(envelope.CoChanges) == 0:
+			log.Printf("no co-changes for %s, stored pairs kept", req.Repo)
+		default:
+			if err := saveCoChanges(req.Repo, envelope.CoChanges); err != nil {
+				annotateStage.Warn("save co-changes failed: %v", err)
+			}
+		}
+		annotateStage.Done(len(envelope.SymbolHistory)+len(envelope.Hotspots)+len(envelope.CoChanges), nil)
+		envelope.Stages = job.Results()
 
 		var buildWG sync.WaitGroup
-		buildWG.Add(2)
+		buildWG.Add(3)
 
 		go func() {
 			defer buildWG.Done()
@@ -228,14 +664,7 @@ func processMessage(
 
 			res := make([]model.RevertedPRPayload, 0, len(reverted))
 			for _, pr := range reverted {
-				res = append(res,
-					model.RevertedPRPayload{
-						Repo:     req.Repo,
-						PR:       pr,
-						Diff:     pr.Diff,
-						Comments: pr.Body,
-					},
-				)
+				res = append(res, revertedPayload(req.Repo, pr))
 			}
 			mu.Lock()
 			envelope.RevertedPRs = res
@@ -262,13 +691,48 @@ func processMessage(
 			mu.Unlock()
 		}()
 
+		go func() {
+			defer buildWG.Done()
+			if len(merged) == 0 {
+				return
+			}
+
+			res := make([]model.MergedPRPayload, 0, len(merged))
+			for _, pr := range merged {
+				res = append(res,
+					model.MergedPRPayload{
+						Repo: req.Repo,
+						PR:   pr,
+					},
+				)
+			}
+			mu.Lock()
+			envelope.MergedPRs = res
+			mu.Unlock()
+		}()
+
 		buildWG.Wait()
-		stages.Wait()
 
-		b, err := json.MarshalIndent(envelope, "", "  ")
+		if stopped() {
+			return
+		}
+
+		emitStage := job.Stage("emit")
+		payload, report, err := redactor.JSON(envelope)
 		if err != nil {
+			emitStage.Done(0, err)
+			job.Fail("emit", err)
+			log.Println("redaction failed:", err)
+			return
+		}
+		payload["redactions"] = report
+		log.Printf("redacted %d value(s) for %s: %v", report.Total(), req.Repo, report)
+
+		b, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			emitStage.Done(0, err)
+			job.Fail("emit", err)
 			log.Println("marshal failed:", err)
-			db.UpdateStatus(req.Repo, "FAILED")
 			return
 		}
 
@@ -276,20 +740,126 @@ func processMessage(
 		log.Println("wrote result.json")
 
 		log.Printf("repo fetch completed for : %s", req.Repo)
-		if err := emitEnvelope(producer, envelope); err != nil {
+		if err := emitEnvelope(producer, payload); err != nil {
+			emitStage.Done(0, err)
+			job.Fail("emit", err)
 			log.Printf("❌ Failed to emit to Kafka: %v", err)
-			db.UpdateStatus(req.Repo, "FAILED")
 			return
 		}
+		emitStage.Done(len(b), nil)
+		if err := db.DeleteCheckpoint(req.Repo); err != nil {
+			log.Println("delete checkpoint failed:", err)
+		}
 
-		db.UpdateStatus(req.Repo, "QUEUED")
+		// Handed off: the repo waits QUEUED until the analyzer picks the
+		// envelope up and moves it on to ANALYZING, INDEXING, then READY.
+		job.SetStatus(progress.StatusQueued)
+	case "backfill":
+		log.Printf("processing repo: %s (backfill)", req.Repo)
+		runBackfill(ctx, req, client, parts[0], parts[1], producer)
 	default:
 		log.Printf("unknown request type: %s", req.Type)
 	}
+	return false
 }
+func revertedPayload(repo string, pr github.MinimalPR) model.RevertedPRPayload {
+	// The risk belongs to the code that was reverted, so prefer the original
+	// change over the revert itself. The chosen diff moves to the payload
+	// rather than travelling twice; the PR keeps its per-file summaries.
+	diff := pr.OriginalDiff
+	if diff != "" {
+		pr.OriginalDiff = ""
+	} else {
+		diff, pr.Diff = pr.Diff, ""
+	}
+	return model.RevertedPRPayload{
+		Repo:     repo,
+		PR:       pr,
+		Diff:     diff,
+		Comments: pr.Body,
+	}
+}
+
+func saveCoChanges(repo string, pairs []github.FileCoupling) error {
+	rows := make([]db.CoChange, 0, len(pairs))
+	for _, p := range pairs {
+		rows = append(rows, db.CoChange{
+			FileA:        p.FileA,
+			FileB:        p.FileB,
+			CoChanges:    p.CoChanges,
+			ChangesA:     p.ChangesA,
+			ChangesB:     p.ChangesB,
+			Support:      p.Support,
+			ConfidenceAB: p.ConfidenceAB,
+			ConfidenceBA: p.ConfidenceBA,
+			Lift:         p.Lift,
+		})
+	}
+	return db.ReplaceCoChanges(repo, rows)
+}
+
+// Keyword document frequencies are stored per source. A sync reads the same
+// window every time, so its counts replace the last sync's; backfill slices
+// never overlap, so theirs add up.
+const (
+	keywordsSync     = "sync"
+	keywordsBackfill = "backfill"
+)
+
+type keywordCounts map[string]keywords.Counts
+
+// past returns the stored counts of the given sources that exist.
+func (k keywordCounts) past(sources ...string) []keywords.Counts {
+	var out []keywords.Counts
+	for _, src := range sources {
+		if c, ok := k[src]; ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// loadKeywordCounts returns a repo's stored keyword counts. Errors only cost
+// ranking quality, so they are logged and an empty set is returned.
+func loadKeywordCounts(repo string) keywordCounts {
+	out := keywordCounts{}
+	rows, err := db.LoadKeywordCounts(repo)
+	if err != nil {
+		log.Printf("load keyword counts for %s failed: %v", repo, err)
+		return out
+	}
+	for src, raw := range rows {
+		var c keywords.Counts
+		if err := json.Unmarshal(raw, &c); err != nil {
+			log.Printf("keyword counts %s/%s unreadable: %v", repo, src, err)
+			continue
+		}
+		out[src] = c
+	}
+	return out
+}
+
+func saveKeywordCounts(repo, source string, c keywords.Counts) error {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return db.SaveKeywordCounts(repo, source, raw)
+}
+
+// mergeKeywordCounts adds up several sets of counts, keeping the stored size
+// bounded.
+func mergeKeywordCounts(counts ...keywords.Counts) keywords.Counts {
+	corpus := keywords.NewCorpus()
+	for _, c := range counts {
+		corpus.Merge(c)
+	}
+	return corpus.Counts(keywords.MaxStoredTerms)
+}
+
 func emitEnvelope(
     producer *ckafka.Producer,
-    envelope AnalysisEnvelope,
+    envelope any,
 ) error {
     bytes, err := json.Marshal(envelope)
     if err != nil {
@@ -334,14 +904,16 @@ func emitEnvelope(
 }
 
 func ProcessWorkflowCrash(
+	ctx context.Context,
 	req *model.IngestRequest,
 	token string,
 	owner string,
 	repo string,
-) *model.WorkflowCrashPayload {
+) (*model.WorkflowCrashPayload, error) {
 	log.Println("ProcessWorkflowCrash:", req.Repo)
 
 	crashes, err := github.FetchWorkflowFailures(
+		ctx,
 		github.NewClient(token),
 		owner,
 		repo,
@@ -350,33 +922,35 @@ func ProcessWorkflowCrash(
 		log.Println("[worker] workflow crash fetch failed:", err)
 		return &model.WorkflowCrashPayload{
 			Crash: []github.WorkflowCrash{},
-		}
+		}, err
 	}
 
 	return &model.WorkflowCrashPayload{
 		Crash: crashes,
-	}
+	}, nil
 }
 
-func ProcessBug(req *model.IngestRequest, token string, owner string, repo string) *model.BugPayload {
+func ProcessBug(ctx context.Context, req *model.IngestRequest, token string, owner string, repo string) (*model.BugPayload, error) {
 	log.Println("ProcessBug:", req.Repo)
 
 	issues, err := github.FetchClosedIssuesRaw(
+		ctx,
 		token,
 		owner,
 		repo,
 	)
 	if err != nil {
 		log.Println("fetch failed:", err)
-		return &model.BugPayload{}
+		return &model.BugPayload{}, err
 	}
 
 	return &model.BugPayload{
 		Issues: issues,
-	}
+	}, nil
 }
 
 func ProcessArchitecture(
+	ctx context.Context,
 	req *model.IngestRequest,
 	token string,
 	owner string,
@@ -384,7 +958,7 @@ func ProcessArchitecture(
 ) *model.ArchPayload {
 	log.Println("ProcessArchitecture:", req.Repo)
 
-	files, err := github.FetchRepoArchitecture(github.NewClient(token), owner, repo)
+	files, err := github.FetchRepoArchitecture(ctx, github.NewClient(token), owner, repo)
 	if err != nil {
 		log.Println("arch fetch failed:", err)
 		return &model.ArchPayload{}
@@ -8,6 +8,29 @@ type IngestRequest struct {
 	Repo        string `json:"repo"`
 	AccessToken string `json:"access_token"`
 	Type        string `json:"type"`
+
+	// Since and ChunkDays only apply to "backfill": how far back to walk,
+	// as a date or RFC 3339 time, and how many days each slice covers.
+	Since     string `json:"since,omitempty"`
+	ChunkDays int    `json:"chunk_days,omitempty"`
+
+	// The rest only apply to "org", where Repo holds the org login.
+	// Concurrency caps how many repos are ingesting at once.
+	InstallationID string            `json:"installation_id,omitempty"`
+	ConnectedBy    string            `json:"connected_by,omitempty"`
+	Filter         *github.OrgFilter `json:"filter,omitempty"`
+	Concurrency    int               `json:"concurrency,omitempty"`
+	// ResumeJob is set on an org request queued again by a worker that was
+	// shutting down; the job carries on under the same id.
+	ResumeJob string `json:"resume_job,omitempty"`
+}
+
+// ControlMessage asks the worker running a repo's job to stop it. Action is
+// "cancel" or "pause"; both stop the job and leave a checkpoint.
+type ControlMessage struct {
+	Repo   string `json:"repo"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
 }
 
 type RevertedPRPayload struct {
@@ -22,6 +45,11 @@ type RejectedPRPayload struct {
 	PR   any    `json:"pr"`
 }
 
+type MergedPRPayload struct {
+	Repo string `json:"repo"`
+	PR   any    `json:"pr"`
+}
+
 type BugPayload struct {
 	Issues []github.Issue
 }
@@ -32,4 +60,7 @@ type WorkflowCrashPayload struct {
 
 type ArchPayload struct {
 	Files []github.ArchFile
-}
\ No newline at end of file
+}
+type SecurityPayload struct {
+	Alerts []github.SecurityAlert
+}
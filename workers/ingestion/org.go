@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"codrel-sentinel/workers/ingestion-worker/control"
+	"codrel-sentinel/workers/ingestion-worker/db"
+	"codrel-sentinel/workers/ingestion-worker/github"
+	"codrel-sentinel/workers/ingestion-worker/kafka"
+	"codrel-sentinel/workers/ingestion-worker/model"
+	"codrel-sentinel/workers/ingestion-worker/progress"
+)
+
+const (
+	defaultOrgConcurrency = 5
+	orgPollInterval       = 15 * time.Second
+	// orgRepoTimeout is how long the org job waits on one repo before it
+	// stops counting it as in flight.
+	orgRepoTimeout = 3 * time.Hour
+)
+
+// orgJobs tracks org fan-outs. They run beside the worker pool so that
+// waiting on their repos never holds a worker slot those repos need.
+var orgJobs sync.WaitGroup
+
+// Outcomes of a repo in an org summary.
+const (
+	orgPending  = "pending"
+	orgRunning  = "running"
+	orgExisting = "existing"
+	orgReady    = "ready"
+	orgFailed   = "failed"
+	orgPaused   = "paused"
+	orgTimedOut = "timed_out"
+)
+
+type OrgRepoOutcome struct {
+	Repo     string     `json:"repo"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	QueuedAt *time.Time `json:"queued_at,omitempty"`
+}
+
+type SkippedRepo struct {
+	Repo   string `json:"repo"`
+	Reason string `json:"reason"`
+}
+
+// OrgSummary is the state of an org job, saved to org_ingestions as it
+// progresses and once more when every repo has finished.
+type OrgSummary struct {
+	Org            string           `json:"org"`
+	JobID          string           `json:"job_id"`
+	InstallationID string           `json:"installation_id"`
+	Total          int              `json:"total"`
+	Counts         map[string]int   `json:"counts"`
+	Repos          []OrgRepoOutcome `json:"repos"`
+	Skipped        []SkippedRepo    `json:"skipped"`
+	StartedAt      time.Time        `json:"started_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+}
+
+func (s *OrgSummary) count() {
+	s.Counts = map[string]int{}
+	for _, r := range s.Repos {
+		s.Counts[r.Status]++
+	}
+	s.Counts["skipped"] = len(s.Skipped)
+}
+
+// runOrg connects every repo of an org the installation can access and the
+// filter keeps. Repos are registered and queued as regular connection
+// requests, at most Concurrency at a time, and the job waits for each to
+// reach READY, FAILED or PAUSED before queueing the next. A worker shutting
+// down queues the request again so another worker resumes the same job; the
+// scheduler resumes one that could not be queued or whose worker died.
+func runOrg(ctx context.Context, req *model.IngestRequest, producer *ckafka.Producer) {
+	org := req.Repo
+
+	filter := github.OrgFilter{}
+	if req.Filter != nil {
+		filter = *req.Filter
+	}
+	if err := filter.Validate(); err != nil {
+		log.Printf("org request for %s: %v", org, err)
+		return
+	}
+
+	token, installationID, err := orgToken(req)
+	if err != nil {
+		log.Printf("org request for %s: %v", org, err)
+		return
+	}
+
+	jobCtx, release := registry.Start(ctx, org)
+	defer release()
+
+	job := progress.NewJob(org, progress.Policy{})
+	prev := loadOrgSummary(req.ResumeJob)
+	if prev != nil {
+		job.ID = prev.JobID
+	}
+	summary := &OrgSummary{
+		Org:            org,
+		JobID:          job.ID,
+		InstallationID: installationID,
+		Skipped:        []SkippedRepo{},
+		StartedAt:      time.Now(),
+	}
+	if prev != nil {
+		summary.StartedAt = prev.StartedAt
+	}
+
+	listStage := job.Stage("list_repos")
+	repos, err := github.FetchInstallationRepos(jobCtx, github.NewClient(token), org)
+	listStage.Done(len(repos), err)
+	if err != nil {
+		log.Printf("listing repos for %s failed: %v", org, err)
+		return
+	}
+
+	// A resumed job keeps the outcome of every repo that settled; the rest
+	// start over as pending and fanOut follows them from their repo status.
+	settled := map[string]OrgRepoOutcome{}
+	if prev != nil {
+		for _, o := range prev.Repos {
+			if o.Status != orgPending && o.Status != orgRunning {
+				settled[o.Repo] = o
+			}
+		}
+	}
+
+	summary.Total = len(repos)
+	for _, r := range repos {
+		if ok, reason := filter.Match(r); !ok {
+			summary.Skipped = append(summary.Skipped, SkippedRepo{Repo: r.FullName, Reason: reason})
+			continue
+		}
+		o, ok := settled[r.FullName]
+		if !ok {
+			o = OrgRepoOutcome{Repo: r.FullName, Status: orgPending}
+		}
+		summary.Repos = append(summary.Repos, o)
+	}
+
+	if prev == nil {
+		filterJSON, _ := json.Marshal(filter)
+		if err := db.StartOrgIngestion(job.ID, org, installationID, filterJSON); err != nil {
+			log.Println("start org ingestion failed:", err)
+		}
+	}
+
+	log.Printf(
+		"org %s | job=%s repos=%d selected=%d skipped=%d",
+		org,
+		job.ID,
+		summary.Total,
+		len(summary.Repos),
+		len(summary.Skipped),
+	)
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultOrgConcurrency
+	}
+
+	fanStage := job.Stage("fan_out")
+	// Each repo's worker mints its own installation token when it starts, so
+	// repos queued hours into the job do not carry an expired one.
+	err = fanOut(jobCtx, summary, concurrency, func(o *OrgRepoOutcome) error {
+		owner, name, _ := strings.Cut(o.Repo, "/")
+		if err := db.RegisterRepo(owner, name, installationID, orgConnectedBy(req)); err != nil {
+			return err
+		}
+		return kafka.PublishRequest(producer, cfg.Ingestion.RequestTopic, model.IngestRequest{
+			Repo: o.Repo,
+			Type: "connection",
+		})
+	})
+
+	status := "DONE"
+	if err != nil {
+		status = "CANCELLED"
+		if errors.Is(err, control.ErrShutdown) {
+			status = requeueOrg(req, producer, job.ID)
+		}
+	} else {
+		now := time.Now()
+		summary.FinishedAt = &now
+	}
+
+	summary.count()
+	if n := summary.Counts[orgFailed] + summary.Counts[orgTimedOut]; n > 0 {
+		fanStage.Warn("%d repo(s) failed or timed out", n)
+	}
+	fanStage.Done(len(summary.Repos), err)
+	saveOrgSummary(summary, status)
+
+	log.Printf("org %s %s | %v", org, status, summary.Counts)
+}
+
+// fanOut queues repos through dispatch, keeping at most concurrency of them
+// in flight, and follows each through the repositories table until it
+// settles. It saves the summary after every poll.
+func fanOut(
+	ctx context.Context,
+	summary *OrgSummary,
+	concurrency int,
+	dispatch func(*OrgRepoOutcome) error,
+) error {
+	ticker := time.NewTicker(orgPollInterval)
+	defer ticker.Stop()
+
+	inFlight := map[int]time.Time{}
+	next := 0
+
+	for {
+		for i, queued := range inFlight {
+			o := &summary.Repos[i]
+			status, errMsg, err := db.GetRepoState(o.Repo)
+			if err != nil {
+				continue
+			}
+			switch {
+			case status == progress.StatusReady:
+				o.Status = orgReady
+			case status == progress.StatusFailed:
+				o.Status, o.Error = orgFailed, errMsg
+			case status == progress.StatusPaused:
+				o.Status = orgPaused
+			case time.Since(queued) > orgRepoTimeout:
+				o.Status = orgTimedOut
+			default:
+				continue
+			}
+			delete(inFlight, i)
+		}
+
+		for len(inFlight) < concurrency && next < len(summary.Repos) {
+			i := next
+			next++
+			o := &summary.Repos[i]
+			if o.Status != orgPending {
+				continue
+			}
+			now := time.Now()
+
+			status, _, err := db.GetRepoState(o.Repo)
+			if err != nil {
+				o.Status, o.Error = orgFailed, err.Error()
+				continue
+			}
+			switch status {
+			case progress.StatusReady, progress.StatusPaused:
+				o.Status = orgExisting
+				continue
+			case progress.StatusQueued, progress.StatusFetching, progress.StatusAnalyzing, progress.StatusIndexing:
+				// Already on its way, from an earlier run of this org or a
+				// sync; follow it without queueing it twice.
+				o.Status = orgRunning
+				inFlight[i] = now
+				continue
+			}
+
+			if err := dispatch(o); err != nil {
+				o.Status, o.Error = orgFailed, fmt.Sprintf("queue: %v", err)
+				continue
+			}
+			o.Status = orgRunning
+			o.QueuedAt = &now
+			inFlight[i] = now
+		}
+
+		summary.count()
+		saveOrgSummary(summary, "RUNNING")
+
+		if next == len(summary.Repos) && len(inFlight) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return control.Cause(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// orgToken returns a token that can list the org's repos and the id of the
+// installation it belongs to. Org requests need a GitHub App: an org job runs
+// for hours, longer than any one installation token lasts, so every repo it
+// queues must be able to mint its own.
+func orgToken(req *model.IngestRequest) (string, string, error) {
+	switch {
+	case githubApp == nil:
+		return "", "", errors.New("org requests need a GitHub App configured")
+	case req.AccessToken != "":
+		if req.InstallationID == "" {
+			return "", "", errors.New("installation_id is required with an access token")
+		}
+		return req.AccessToken, req.InstallationID, nil
+	case req.InstallationID != "":
+		token, err := githubApp.GetInstallationTokenByID(req.InstallationID)
+		return token, req.InstallationID, err
+	default:
+		return githubApp.GetOrgInstallationToken(req.Repo)
+	}
+}
+
+func orgConnectedBy(req *model.IngestRequest) string {
+	if req.ConnectedBy != "" {
+		return req.ConnectedBy
+	}
+	return "org:" + req.Repo
+}
+
+// requeueOrg queues an interrupted org job so another worker resumes it and
+// returns the status to record: still RUNNING if it was queued, otherwise
+// INTERRUPTED for the scheduler to resume. The token is dropped, as the
+// resuming worker mints a fresh one from the installation id.
+func requeueOrg(req *model.IngestRequest, producer *ckafka.Producer, jobID string) string {
+	next := *req
+	next.ResumeJob = jobID
+	next.AccessToken = ""
+	if err := kafka.PublishRequest(producer, cfg.Ingestion.RequestTopic, next); err != nil {
+		log.Printf("requeue org %s failed: %v", req.Repo, err)
+		return "INTERRUPTED"
+	}
+	return "RUNNING"
+}
+
+// loadOrgSummary returns the saved summary of the job being resumed, or nil
+// for a fresh org request.
+func loadOrgSummary(jobID string) *OrgSummary {
+	if jobID == "" {
+		return nil
+	}
+	raw, err := db.LoadOrgIngestion(jobID)
+	if err != nil || raw == nil {
+		log.Printf("org job %s not found, starting over: %v", jobID, err)
+		return nil
+	}
+	var s OrgSummary
+	if err := json.Unmarshal(raw, &s); err != nil {
+		log.Printf("decode org summary %s failed, starting over: %v", jobID, err)
+		return nil
+	}
+	return &s
+}
+
+func saveOrgSummary(summary *OrgSummary, status string) {
+	b, err := json.Marshal(summary)
+	if err != nil {
+		log.Println("encode org summary failed:", err)
+		return
+	}
+	if err := db.SaveOrgIngestion(summary.JobID, status, b); err != nil {
+		log.Println("save org summary failed:", err)
+	}
+}
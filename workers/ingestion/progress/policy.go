@@ -0,0 +1,39 @@
+package progress
+
+import "strings"
+
+// DefaultFatalStages fail the whole job when they fail. Without closed PRs
+// there is nothing worth emitting; every other stage degrades to an empty,
+// explicitly failed section of the envelope.
+var DefaultFatalStages = []string{"pr_buckets"}
+
+// Policy is the set of stages whose failure fails the job.
+type Policy map[string]bool
+
+// ParsePolicy reads a comma-separated stage list, as found in
+// INGEST_FATAL_STAGES. An empty value gives DefaultFatalStages; "none"
+// makes every stage non-fatal.
+func ParsePolicy(value string) Policy {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return NewPolicy(DefaultFatalStages...)
+	}
+	if value == "none" {
+		return Policy{}
+	}
+	return NewPolicy(strings.Split(value, ",")...)
+}
+
+func NewPolicy(stages ...string) Policy {
+	p := Policy{}
+	for _, s := range stages {
+		if s = strings.TrimSpace(s); s != "" {
+			p[s] = true
+		}
+	}
+	return p
+}
+
+func (p Policy) Fatal(stage string) bool {
+	return p[stage]
+}
@@ -0,0 +1,278 @@
+package progress
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"codrel-sentinel/workers/ingestion-worker/db"
+)
+
+// Repo statuses, matching the repo_status enum in the dashboard schema.
+const (
+	StatusPaused    = "PAUSED"
+	StatusQueued    = "QUEUED"
+	StatusFetching  = "FETCHING"
+	StatusAnalyzing = "ANALYZING"
+	StatusIndexing  = "INDEXING"
+	StatusReady     = "READY"
+	StatusFailed    = "FAILED"
+)
+
+// Stage outcomes. They go into the envelope as is and into
+// ingestion_job_stages upper-cased.
+const (
+	ResultOK      = "ok"
+	ResultPartial = "partial"
+	ResultFailed  = "failed"
+	ResultSkipped = "skipped"
+)
+
+// Coverage says what a stage looked at, so an empty result can be told
+// apart from a result that was cut short.
+type Coverage struct {
+	Since     *time.Time `json:"since,omitempty"`
+	Until     time.Time  `json:"until"`
+	Items     int        `json:"items"`
+	Limit     int        `json:"limit,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// Result is the outcome of one stage as carried in the analysis envelope.
+type Result struct {
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Warnings   []string  `json:"warnings,omitempty"`
+	Fatal      bool      `json:"fatal,omitempty"`
+	Restored   bool      `json:"restored,omitempty"`
+	Coverage   Coverage  `json:"coverage"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Job is one ingestion run for a repo. Every stage it starts gets a row in
+// ingestion_job_stages under the same job id. Progress is best effort: a
+// database error is logged and never stops ingestion.
+type Job struct {
+	ID     string
+	Repo   string
+	Policy Policy
+
+	mu         sync.Mutex
+	failed     bool
+	fatalStage string
+	fatalErr   error
+	results    map[string]Result
+}
+
+func NewJob(repo string, policy Policy) *Job {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return &Job{
+		ID:      hex.EncodeToString(b),
+		Repo:    repo,
+		Policy:  policy,
+		results: map[string]Result{},
+	}
+}
+
+// SetStatus moves the repo to one of the repo_status values. Starting a
+// fetch clears the error left by an earlier failed run.
+func (j *Job) SetStatus(status string) {
+	if status == StatusFetching {
+		if err := db.ClearError(j.Repo); err != nil {
+			log.Printf("[progress] clear error failed for %s: %v", j.Repo, err)
+		}
+	}
+	_ = db.UpdateStatus(j.Repo, status)
+}
+
+// Fail marks the repo FAILED with the stage and cause, so the dashboard can
+// show why. Only the first failure of a job is recorded.
+func (j *Job) Fail(stage string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.failed {
+		return
+	}
+	j.failed = true
+	_ = db.MarkFailed(j.Repo, fmt.Sprintf("%s: %v", stage, err))
+}
+
+// Abort reports whether a stage the policy treats as fatal has failed, and
+// if so marks the repo FAILED with that stage's error.
+func (j *Job) Abort() bool {
+	stage, err := j.Fatal()
+	if err == nil {
+		return false
+	}
+	j.Fail(stage, err)
+	return true
+}
+
+// Fatal returns the first stage the policy treats as fatal that failed, and
+// its error, without touching the repo status.
+func (j *Job) Fatal() (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.fatalStage, j.fatalErr
+}
+
+// Results returns the outcome of every finished stage, keyed by stage name.
+func (j *Job) Results() map[string]Result {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make(map[string]Result, len(j.results))
+	for k, v := range j.results {
+		out[k] = v
+	}
+	return out
+}
+
+// Restore records a stage carried over from the checkpoint of an earlier,
+// stopped job instead of being run again.
+func (j *Job) Restore(name string, res Result) {
+	res.Restored = true
+
+	j.mu.Lock()
+	j.results[name] = res
+	j.mu.Unlock()
+
+	log.Printf("[progress] %s | stage=%s restored from checkpoint", j.Repo, name)
+}
+
+// Stage is one step of a job. End it with exactly one of Done or Skip.
+type Stage struct {
+	job     *Job
+	id      int64
+	name    string
+	started time.Time
+
+	mu       sync.Mutex
+	warnings []string
+	since    *time.Time
+	until    time.Time
+	limit    int
+}
+
+func (j *Job) Stage(name string) *Stage {
+	s := &Stage{job: j, name: name, started: time.Now()}
+
+	id, err := db.StartStage(j.ID, j.Repo, name)
+	if err != nil {
+		log.Printf("[progress] start stage %s failed for %s: %v", name, j.Repo, err)
+	}
+	s.id = id
+
+	log.Printf("[progress] %s | job=%s stage=%s started", j.Repo, j.ID, name)
+	return s
+}
+
+// Cover records the window the stage read and the item cap it applies. A
+// zero since means no window; a zero limit means no cap.
+func (s *Stage) Cover(since time.Time, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !since.IsZero() {
+		s.since = &since
+	}
+	s.limit = limit
+}
+
+// CoverRange is Cover for a window that ends before now, such as a backfill
+// slice.
+func (s *Stage) CoverRange(since, until time.Time, limit int) {
+	s.Cover(since, limit)
+	s.mu.Lock()
+	s.until = until
+	s.mu.Unlock()
+}
+
+// Warn records a problem that did not stop the stage. A stage that finishes
+// with warnings is partial.
+func (s *Stage) Warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("[progress] %s | stage=%s warning: %s", s.job.Repo, s.name, msg)
+
+	s.mu.Lock()
+	s.warnings = append(s.warnings, msg)
+	s.mu.Unlock()
+}
+
+// Done closes the stage with the number of items it produced. A non-nil err
+// marks it failed, and fatal if the job policy says so.
+func (s *Stage) Done(items int, err error) Result {
+	return s.finish("", items, err)
+}
+
+// Skip closes a stage that had nothing it could fetch, such as a feature
+// disabled on the repo.
+func (s *Stage) Skip(reason string) Result {
+	s.Warn("%s", reason)
+	return s.finish(ResultSkipped, 0, nil)
+}
+
+func (s *Stage) finish(status string, items int, err error) Result {
+	s.mu.Lock()
+	if status == "" {
+		switch {
+		case err != nil:
+			status = ResultFailed
+		case len(s.warnings) > 0:
+			status = ResultPartial
+		default:
+			status = ResultOK
+		}
+	}
+	until := s.until
+	if until.IsZero() {
+		until = s.started
+	}
+	res := Result{
+		Status:   status,
+		Warnings: s.warnings,
+		Coverage: Coverage{
+			Since: s.since,
+			Until: until,
+			Items: items,
+			Limit: s.limit,
+		},
+		StartedAt:  s.started,
+		DurationMs: time.Since(s.started).Milliseconds(),
+	}
+	s.mu.Unlock()
+
+	res.Coverage.Truncated = res.Coverage.Limit > 0 && items >= res.Coverage.Limit
+	if err != nil {
+		res.Error = err.Error()
+		res.Fatal = s.job.Policy.Fatal(s.name)
+	}
+
+	s.job.mu.Lock()
+	s.job.results[s.name] = res
+	if res.Fatal && s.job.fatalErr == nil {
+		s.job.fatalStage, s.job.fatalErr = s.name, err
+	}
+	s.job.mu.Unlock()
+
+	log.Printf(
+		"[progress] %s | stage=%s status=%s items=%d warnings=%d took=%dms",
+		s.job.Repo,
+		s.name,
+		status,
+		items,
+		len(res.Warnings),
+		res.DurationMs,
+	)
+
+	if s.id != 0 {
+		if err := db.FinishStage(s.id, strings.ToUpper(status), items, res.Warnings, res.Error); err != nil {
+			log.Printf("[progress] finish stage %s failed for %s: %v", s.name, s.job.Repo, err)
+		}
+	}
+	return res
+}
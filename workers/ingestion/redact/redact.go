@@ -0,0 +1,262 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule replaces every match of Pattern. When Group is set only that capture
+// group is replaced, so "password = \"hunter22\"" keeps its key.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Group   int    `json:"group,omitempty"`
+
+	re *regexp.Regexp
+}
+
+var builtinRules = []Rule{
+	{Name: "private_key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "github_token", Pattern: `\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`},
+	{Name: "aws_access_key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws_secret_key", Pattern: `(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`, Group: 1},
+	{Name: "slack_token", Pattern: `\bxox[abposr]-[A-Za-z0-9-]{10,}\b`},
+	{Name: "google_api_key", Pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "stripe_key", Pattern: `\b(?:sk|rk)_(?:live|test)_[0-9A-Za-z]{16,}\b`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`},
+	{Name: "bearer_token", Pattern: `(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`, Group: 1},
+	{Name: "url_credentials", Pattern: `\b[a-z][a-z0-9+.-]*://[^\s:@/]+:([^\s@/]+)@`, Group: 1},
+	{Name: "quoted_secret", Pattern: `(?i)(?:api[_-]?key|secret|passw(?:or)?d|token|access[_-]?key|client[_-]?secret)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`, Group: 1},
+	{Name: "env_secret", Pattern: `(?m)^\s*[+-]?\s*(?:export\s+)?[A-Z0-9_]*(?:KEY|SECRET|TOKEN|PASSWORD|PASSWD)[A-Z0-9_]*\s*=\s*([^\s"']{6,})`, Group: 1},
+	{Name: "email", Pattern: `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`},
+	{Name: "internal_host", Pattern: `(?i)\b(?:[a-z0-9-]+\.){2,}(?:internal|local|corp|lan|intranet|localdomain)\b`},
+	{Name: "private_ip", Pattern: `\b(?:10\.\d{1,3}\.\d{1,3}\.\d{1,3}|192\.168\.\d{1,3}\.\d{1,3}|172\.(?:1[6-9]|2\d|3[01])\.\d{1,3}\.\d{1,3})\b`},
+}
+
+const (
+	entropyRule      = "high_entropy"
+	entropyMinLength = 32
+	entropyThreshold = 4.3
+)
+
+var (
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+	hexOnly          = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// identifierKeys are JSON keys whose string values name things rather than
+// carry free text: paths, SHAs, URLs, branches and code identifiers. They are
+// left alone so the analyzer can still key on them; a path such as
+// "assets/logo@2x.png" would otherwise read as an email address.
+var identifierKeys = map[string]bool{
+	"repo": true, "job_id": true, "full_name": true,
+	"sha": true, "commit_sha": true, "head_sha": true, "last_green_sha": true,
+	"merge_commit_sha": true, "reverted_shas": true, "rollback_to": true,
+	"file": true, "files": true, "file_a": true, "file_b": true, "filename": true,
+	"path": true, "paths": true, "old_path": true, "new_path": true,
+	"matched_files": true, "error_files": true, "node": true, "nodes": true,
+	"html_url": true, "url": true, "pr_url": true,
+	"branch": true, "source_branch": true, "base_branch": true, "default_branch": true,
+	"ref": true, "refs": true, "tag": true,
+	"name": true, "function": true, "functions": true, "package": true, "identifiers": true,
+}
+
+// Report counts redactions per rule name.
+type Report map[string]int
+
+func (r Report) Total() int {
+	n := 0
+	for _, c := range r {
+		n += c
+	}
+	return n
+}
+
+// Redactor is safe for concurrent use. The same input always produces the
+// same output.
+type Redactor struct {
+	rules []Rule
+}
+
+// New compiles the built-in rules followed by any extra rules.
+func New(extra []Rule) (*Redactor, error) {
+	rules := make([]Rule, 0, len(builtinRules)+len(extra))
+	rules = append(rules, builtinRules...)
+	rules = append(rules, extra...)
+
+	for i := range rules {
+		re, err := regexp.Compile(rules[i].Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %q: %w", rules[i].Name, err)
+		}
+		if rules[i].Group > re.NumSubexp() {
+			return nil, fmt.Errorf("redaction rule %q: group %d out of range", rules[i].Name, rules[i].Group)
+		}
+		rules[i].re = re
+	}
+
+	return &Redactor{rules: rules}, nil
+}
+
+// LoadRules reads extra rules from a JSON array of {name, pattern, group}.
+func LoadRules(path string) ([]Rule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("parse redaction rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+func marker(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// String redacts s and adds the number of replacements to report.
+func (r *Redactor) String(s string, report Report) string {
+	for _, rule := range r.rules {
+		matches := rule.re.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[2*rule.Group], m[2*rule.Group+1]
+			if start < 0 || start < last || strings.HasPrefix(s[start:end], "[REDACTED:") {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(marker(rule.Name))
+			last = end
+			report[rule.Name]++
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+
+	return entropyCandidate.ReplaceAllStringFunc(s, func(tok string) string {
+		if !isHighEntropy(tok) {
+			return tok
+		}
+		report[entropyRule]++
+		return marker(entropyRule)
+	})
+}
+
+// Value redacts every free-text string inside a decoded JSON value in place.
+// Strings, and lists of strings, under identifierKeys are kept as they are.
+func (r *Redactor) Value(v any, report Report) any {
+	switch t := v.(type) {
+	case string:
+		return r.String(t, report)
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if identifierKeys[k] && isIdentifier(t[k]) {
+				continue
+			}
+			t[k] = r.Value(t[k], report)
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = r.Value(t[i], report)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// isIdentifier reports whether v is a string or a list of strings, so an
+// identifier key holding an object is still walked.
+func isIdentifier(v any) bool {
+	switch t := v.(type) {
+	case string:
+		return true
+	case []any:
+		for _, item := range t {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// JSON round-trips v through encoding/json and redacts every free-text
+// string in it.
+func (r *Redactor) JSON(v any) (map[string]any, Report, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, nil, err
+	}
+
+	report := Report{}
+	r.Value(out, report)
+	return out, report, nil
+}
+
+// isHighEntropy flags long random-looking tokens. Hex-only strings are left
+// alone because commit SHAs are everywhere in the payload.
+func isHighEntropy(tok string) bool {
+	if len(tok) < entropyMinLength || hexOnly.MatchString(tok) {
+		return false
+	}
+
+	var upper, lower, digit bool
+	for _, c := range tok {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	if !(upper && lower && digit) {
+		return false
+	}
+
+	return shannonEntropy(tok) >= entropyThreshold
+}
+
+// shannonEntropy walks byte counts in a fixed order so the float result, and
+// therefore the redaction decision, is identical on every run.
+func shannonEntropy(s string) float64 {
+	var freq [256]int
+	for i := 0; i < len(s); i++ {
+		freq[s[i]]++
+	}
+	n := float64(len(s))
+	var h float64
+	for _, c := range freq {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,283 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"codrel-sentinel/workers/ingestion-worker/db"
+	"codrel-sentinel/workers/ingestion-worker/github"
+	"codrel-sentinel/workers/ingestion-worker/kafka"
+	"codrel-sentinel/workers/ingestion-worker/model"
+)
+
+// leaderLockKey is the Postgres advisory lock every scheduler instance
+// competes for; only the holder publishes.
+const leaderLockKey int64 = 0x73796e63 // "sync"
+
+type Config struct {
+	// RequestTopic is where re-sync and backfill requests are published.
+	RequestTopic string
+	// Interval is how often a healthy repo is re-synced.
+	Interval time.Duration
+	// Jitter spreads due times by up to this fraction of the delay, so repos
+	// connected together do not stay in lockstep.
+	Jitter float64
+	// RetryBase is the first retry delay for a FAILED repo; it doubles per
+	// consecutive failure up to MaxBackoff.
+	RetryBase  time.Duration
+	MaxBackoff time.Duration
+	// StaleAfter is how long a repo may sit in an in-progress status before
+	// it is treated as failed, e.g. after a worker died mid-job.
+	StaleAfter time.Duration
+	// BackfillStaleAfter is how long a running backfill may go without
+	// finishing a slice before it is queued again.
+	BackfillStaleAfter time.Duration
+	// OrgStaleAfter is how long a running org job may go without saving its
+	// progress before it is resumed elsewhere. Org jobs save every poll.
+	OrgStaleAfter time.Duration
+	Tick          time.Duration
+	Batch         int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:   24 * time.Hour,
+		Jitter:     0.1,
+		RetryBase:  30 * time.Minute,
+		MaxBackoff: 24 * time.Hour,
+		StaleAfter: 6 * time.Hour,
+
+		BackfillStaleAfter: time.Hour,
+		OrgStaleAfter:      15 * time.Minute,
+		Tick:               time.Minute,
+		Batch:              50,
+	}
+}
+
+type Scheduler struct {
+	cfg      Config
+	producer *ckafka.Producer
+	rng      *rand.Rand
+
+	// leader holds the advisory lock while this instance leads.
+	leader *sql.Conn
+}
+
+func New(cfg Config, producer *ckafka.Producer) *Scheduler {
+	return &Scheduler{
+		cfg:      cfg,
+		producer: producer,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Run ticks until ctx ends. Every instance runs it; the ones that do not hold
+// the leader lock only retry taking it.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("[scheduler] started | interval=%s tick=%s", s.cfg.Interval, s.cfg.Tick)
+
+	ticker := time.NewTicker(s.cfg.Tick)
+	defer ticker.Stop()
+	defer s.resign()
+
+	for {
+		if s.lead(ctx) {
+			s.tick(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead reports whether this instance holds the leader lock, taking it if it
+// is free. A dropped connection loses the lock, so it is checked each time.
+func (s *Scheduler) lead(ctx context.Context) bool {
+	if s.leader != nil {
+		if err := s.leader.PingContext(ctx); err == nil {
+			return true
+		}
+		log.Println("[scheduler] lost leader connection")
+		s.resign()
+	}
+
+	conn, ok, err := db.TryAdvisoryLock(ctx, leaderLockKey)
+	if err != nil {
+		log.Println("[scheduler] leader lock failed:", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	log.Println("[scheduler] acquired leadership")
+	s.leader = conn
+	return true
+}
+
+func (s *Scheduler) resign() {
+	if s.leader != nil {
+		db.ReleaseAdvisoryLock(s.leader, leaderLockKey)
+		s.leader = nil
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	due, err := db.DueForSync(s.cfg.Batch)
+	if err != nil {
+		log.Println("[scheduler] listing due repos failed:", err)
+		return
+	}
+
+	now := time.Now()
+	for _, c := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		publish, next, failures := s.decide(c, now)
+		if publish {
+			if err := s.publish(c.Repo, "sync"); err != nil {
+				log.Printf("[scheduler] publish sync for %s failed: %v", c.Repo, err)
+				continue
+			}
+			log.Printf("[scheduler] queued sync for %s | failures=%d next=%s", c.Repo, failures, next.Format(time.RFC3339))
+		}
+
+		if err := db.ScheduleSync(c.Repo, next, failures, publish); err != nil {
+			log.Printf("[scheduler] schedule %s failed: %v", c.Repo, err)
+		}
+	}
+
+	s.resumeBackfills(ctx)
+	s.resumeOrgs(ctx)
+}
+
+// resumeBackfills queues again the backfills that stalled or were paused
+// along with their repo. The worker resumes each from its saved cursor.
+func (s *Scheduler) resumeBackfills(ctx context.Context) {
+	repos, err := db.StalledBackfills(s.cfg.BackfillStaleAfter, s.cfg.Batch)
+	if err != nil {
+		log.Println("[scheduler] listing stalled backfills failed:", err)
+		return
+	}
+
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.publish(repo, "backfill"); err != nil {
+			log.Printf("[scheduler] publish backfill for %s failed: %v", repo, err)
+			continue
+		}
+		if err := db.QueueBackfill(repo); err != nil {
+			log.Printf("[scheduler] mark backfill %s queued failed: %v", repo, err)
+		}
+		log.Printf("[scheduler] queued backfill resume for %s", repo)
+	}
+}
+
+// resumeOrgs queues again the org jobs that were interrupted or whose worker
+// died. The resuming worker keeps the outcomes already settled.
+func (s *Scheduler) resumeOrgs(ctx context.Context) {
+	jobs, err := db.StalledOrgIngestions(s.cfg.OrgStaleAfter, s.cfg.Batch)
+	if err != nil {
+		log.Println("[scheduler] listing stalled org jobs failed:", err)
+		return
+	}
+
+	for _, o := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		req := model.IngestRequest{
+			Repo:           o.Org,
+			Type:           "org",
+			InstallationID: o.InstallationID,
+			ResumeJob:      o.JobID,
+		}
+		if len(o.Filter) > 0 {
+			var f github.OrgFilter
+			if err := json.Unmarshal(o.Filter, &f); err != nil {
+				log.Printf("[scheduler] org job %s has an unreadable filter: %v", o.JobID, err)
+				continue
+			}
+			req.Filter = &f
+		}
+		if err := kafka.PublishRequest(s.producer, s.cfg.RequestTopic, req); err != nil {
+			log.Printf("[scheduler] publish org resume for %s failed: %v", o.Org, err)
+			continue
+		}
+		if err := db.QueueOrgIngestion(o.JobID); err != nil {
+			log.Printf("[scheduler] mark org job %s queued failed: %v", o.JobID, err)
+		}
+		log.Printf("[scheduler] queued org resume for %s | job=%s", o.Org, o.JobID)
+	}
+}
+
+// decide works out whether a due repo gets a sync now and when it is next
+// due. Repos seen for the first time are only scheduled, since they were
+// just ingested on connection.
+func (s *Scheduler) decide(c db.SyncCandidate, now time.Time) (bool, time.Time, int) {
+	if !c.Scheduled {
+		return false, now.Add(s.jitter(s.cfg.Interval)), 0
+	}
+
+	switch c.Status {
+	case "READY":
+		return true, now.Add(s.jitter(s.cfg.Interval)), 0
+
+	case "FAILED":
+		failures := c.Failures + 1
+		return true, now.Add(s.jitter(s.backoff(failures))), failures
+
+	default:
+		// QUEUED, FETCHING, ANALYZING or INDEXING: a job is in flight. Look
+		// again later unless it has been stuck long enough to count as dead.
+		if now.Sub(c.UpdatedAt) < s.cfg.StaleAfter {
+			return false, now.Add(s.cfg.Tick * 10), c.Failures
+		}
+		failures := c.Failures + 1
+		return true, now.Add(s.jitter(s.backoff(failures))), failures
+	}
+}
+
+func (s *Scheduler) backoff(failures int) time.Duration {
+	d := s.cfg.RetryBase
+	for i := 1; i < failures && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.cfg.MaxBackoff {
+		d = s.cfg.MaxBackoff
+	}
+	return d
+}
+
+// jitter returns d moved by a random amount of up to cfg.Jitter of itself
+// either way.
+func (s *Scheduler) jitter(d time.Duration) time.Duration {
+	if s.cfg.Jitter <= 0 {
+		return d
+	}
+	spread := float64(d) * s.cfg.Jitter
+	return d + time.Duration((s.rng.Float64()*2-1)*spread)
+}
+
+// publish queues a request of the given type. It carries no token; the
+// worker mints an installation token when it picks the request up, so a
+// backlog cannot outlive the token.
+func (s *Scheduler) publish(repo, kind string) error {
+	return kafka.PublishRequest(s.producer, s.cfg.RequestTopic, model.IngestRequest{
+		Repo: repo,
+		Type: kind,
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 )
@@ -21,7 +20,7 @@ type GeminiResponse struct {
 }
 
 func buildFinalComment(prompt string) (string, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
+	apiKey := cfg.Sentinel.GeminiAPIKey
 	if apiKey == "" {
 		return "", fmt.Errorf("missing GEMINI_API_KEY")
 	}
@@ -74,6 +73,7 @@ func buildPrompt(
 	files []ChangedFile,
 	diffMap map[string]string,
 	risk *RiskResponse,
+	coChanges []CoChangeHint,
 ) string {
 	var diffBlock []string
 	for f, d := range diffMap {
@@ -148,8 +148,12 @@ strings.Join(diffBlock, "\n\n") + "\n\n" +
 "Historical Context (factual):\n" +
 string(riskJSON) + "\n\n" +
 
+"Files Usually Changed Together (missing from this PR):\n" +
+formatCoChangeHints(coChanges) + "\n\n" +
+
 "Rules:\n" +
 "- Warn only if historical evidence exists\n" +
+"- Mention a missing co-changed file only if it is plausibly required by this change\n" +
 "- If risk score < 0.3 and no matches, state clearly that no significant risk was found\n" +
 "- Never invent incidents\n",
 )
@@ -0,0 +1,30 @@
+package main
+
+// Config is what the PR worker reads at startup; see the shared config
+// package for the tag format.
+type Config struct {
+	Kafka    KafkaConfig    `yaml:"kafka"`
+	Database DatabaseConfig `yaml:"database"`
+	Sentinel SentinelConfig `yaml:"sentinel"`
+}
+
+// KafkaConfig keeps the PR worker's own default of a local broker rather
+// than the compose hostname the other workers use.
+type KafkaConfig struct {
+	Brokers string `yaml:"brokers" env:"KAFKA_BROKERS" default:"localhost:9092" required:"true"`
+}
+
+// DatabaseConfig is optional here: without it the worker still comments on
+// PRs, and recording events and co-change lookups fail and are logged.
+type DatabaseConfig struct {
+	URL string `yaml:"url" env:"DATABASE_URL" secret:"true"`
+}
+
+type SentinelConfig struct {
+	Topic         string `yaml:"topic" env:"SENTINEL_TOPIC" default:"sentinelbot.events" required:"true"`
+	ConsumerGroup string `yaml:"consumer_group" env:"SENTINEL_CONSUMER_GROUP" default:"pr-worker-v2" required:"true"`
+	RiskAPIURL    string `yaml:"risk_api_url" env:"RISK_API_URL" default:"http://localhost:3000" required:"true"`
+	GeminiAPIKey  string `yaml:"gemini_api_key" env:"GEMINI_API_KEY" secret:"true"`
+}
+
+var cfg Config
@@ -2,9 +2,15 @@ package main
 
 import (
 	"context"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v61/github"
+
+	"codrel-sentinel/workers/shared/depgraph"
+	"codrel-sentinel/workers/shared/diff"
 )
 
 
@@ -34,6 +40,7 @@ func fetchPRFiles(
 			all = append(all, ChangedFile{
 				Path:  f.GetFilename(),
 				Patch: f.GetPatch(),
+				Hunks: diff.ParseHunks(f.GetPatch()),
 			})
 		}
 
@@ -46,20 +53,151 @@ func fetchPRFiles(
 	return all, nil
 }
 
-func selectCriticalFiles(files []ChangedFile) []string {
+// graphCacheSize bounds how many import graphs are kept; the oldest goes
+// first.
+const graphCacheSize = 16
+
+// graphCache keeps import graphs per repo and default-branch head, so the PR
+// events of a busy repo share one tarball download until the branch moves,
+// whatever commit each PR was based on.
+var graphCache = struct {
+	sync.Mutex
+	entries map[string]*graphEntry
+}{entries: map[string]*graphEntry{}}
+
+type graphEntry struct {
+	ready   chan struct{}
+	graph   *depgraph.Graph
+	err     error
+	created time.Time
+}
+
+// cachedImportGraph returns the import graph of the repo at the head of
+// branch, building it at most once however many events ask for it together.
+// Failed builds are not kept.
+func cachedImportGraph(
+	ctx context.Context,
+	client *github.Client,
+	ev PREvent,
+	branch string,
+) (*depgraph.Graph, error) {
+	sha, _, err := client.Repositories.GetCommitSHA1(ctx, ev.Owner, ev.Repo, branch, "")
+	if err != nil {
+		return nil, err
+	}
+	key := ev.Owner + "/" + ev.Repo + "@" + sha
+
+	graphCache.Lock()
+	e, ok := graphCache.entries[key]
+	if !ok {
+		e = &graphEntry{ready: make(chan struct{}), created: time.Now()}
+		graphCache.entries[key] = e
+		evictGraphs()
+	}
+	graphCache.Unlock()
+
+	if !ok {
+		e.graph, e.err = fetchImportGraph(ctx, client, ev, sha)
+		if e.err != nil {
+			graphCache.Lock()
+			delete(graphCache.entries, key)
+			graphCache.Unlock()
+		}
+		close(e.ready)
+	}
+
+	select {
+	case <-e.ready:
+		return e.graph, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// evictGraphs drops the oldest entries past graphCacheSize. The caller holds
+// the lock.
+func evictGraphs() {
+	for len(graphCache.entries) > graphCacheSize {
+		var oldest string
+		for key, e := range graphCache.entries {
+			if oldest == "" || e.created.Before(graphCache.entries[oldest].created) {
+				oldest = key
+			}
+		}
+		delete(graphCache.entries, oldest)
+	}
+}
+
+// fetchImportGraph builds the import graph of the repo at ref from a single
+// tarball download.
+func fetchImportGraph(
+	ctx context.Context,
+	client *github.Client,
+	ev PREvent,
+	ref string,
+) (*depgraph.Graph, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+
+	link, _, err := client.Repositories.GetArchiveLink(
+		ctx,
+		ev.Owner,
+		ev.Repo,
+		github.Tarball,
+		&github.RepositoryContentGetOptions{Ref: ref},
+		3,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g := depgraph.New()
+	if err := g.LoadURL(ctx, link.String()); err != nil {
+		return nil, err
+	}
+	g.Resolve()
+	return g, nil
+}
+
+// selectCriticalFiles ranks changed files by how many packages and modules
+// transitively import them, falling back to file type when no import graph
+// is available or nothing depends on them.
+func selectCriticalFiles(files []ChangedFile, graph *depgraph.Graph) []string {
 	if len(files) <= 10 {
 		return extractPaths(files)
 	}
 
-	var critical []string
+	type ranked struct {
+		path       string
+		dependents int
+		typed      bool
+	}
+
+	var candidates []ranked
 	for _, f := range files {
-		if strings.Contains(f.Path, "Dockerfile") ||
-			strings.HasSuffix(f.Path, ".ts") ||
-			strings.HasSuffix(f.Path, ".go") ||
-			strings.HasSuffix(f.Path, ".js") ||
-			strings.HasSuffix(f.Path, ".json") {
-			critical = append(critical, f.Path)
+		r := ranked{path: f.Path, typed: isCriticalType(f.Path)}
+		if graph != nil {
+			if radius := graph.Radius([]string{f.Path}, 0); radius != nil {
+				r.dependents = radius.Transitive
+			}
+		}
+		if r.dependents == 0 && !r.typed {
+			continue
+		}
+		candidates = append(candidates, r)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].dependents != candidates[j].dependents {
+			return candidates[i].dependents > candidates[j].dependents
 		}
+		return candidates[i].typed && !candidates[j].typed
+	})
+
+	var critical []string
+	for _, c := range candidates {
+		critical = append(critical, c.path)
 		if len(critical) >= 5 {
 			break
 		}
@@ -68,6 +206,14 @@ func selectCriticalFiles(files []ChangedFile) []string {
 	return critical
 }
 
+func isCriticalType(path string) bool {
+	return strings.Contains(path, "Dockerfile") ||
+		strings.HasSuffix(path, ".ts") ||
+		strings.HasSuffix(path, ".go") ||
+		strings.HasSuffix(path, ".js") ||
+		strings.HasSuffix(path, ".json")
+}
+
 func extractPaths(files []ChangedFile) []string {
 	out := make([]string, 0, len(files))
 	for _, f := range files {
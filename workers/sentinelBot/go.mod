@@ -3,6 +3,7 @@ module codrel-sentinel/workers/sentinelBot
 go 1.24.0
 
 require (
+	codrel-sentinel/workers/shared v0.0.0
 	github.com/confluentinc/confluent-kafka-go v1.9.2
 	github.com/google/go-github/v61 v61.0.0
 	github.com/joho/godotenv v1.5.1
@@ -16,4 +17,7 @@ require (
 	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
 	google.golang.org/appengine v1.4.0 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace codrel-sentinel/workers/shared => ../shared
@@ -10,6 +10,8 @@ import (
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/joho/godotenv"
+
+	shared "codrel-sentinel/workers/shared/config"
 )
 
 type PREvent struct {
@@ -22,13 +24,16 @@ type PREvent struct {
 func main() {
 	_ = godotenv.Load()
 
-	brokers := getenv("KAFKA_BROKERS", "localhost:9092")
-	topic := "sentinelbot.events"
-	groupID := "pr-worker-v2"
+	if err := shared.Load(&cfg); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("sentinelBot config:\n%s", shared.Dump(&cfg))
+
+	topic := cfg.Sentinel.Topic
 
 	c, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": brokers,
-		"group.id":          groupID,
+		"bootstrap.servers": cfg.Kafka.Brokers,
+		"group.id":          cfg.Sentinel.ConsumerGroup,
 		"auto.offset.reset": "earliest",
 	})
 	if err != nil {
@@ -74,10 +79,3 @@ func main() {
 		}
 	}
 }
-
-func getenv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
\ No newline at end of file
@@ -6,13 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v61/github"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	"codrel-sentinel/workers/shared/diff"
 )
 
 func callRiskAPI(
@@ -23,16 +25,13 @@ func callRiskAPI(
 
 	reqBody := RiskRequest{
 		Repo:   ev.Owner + "/" + ev.Repo,
-		Files: files,
+		Files:  files,
 		Change: change,
 	}
 
 	b, _ := json.Marshal(reqBody)
 
-	url := os.Getenv("RISK_API_URL")
-	if url == "" {
-		url = "http://localhost:3000"
-	}
+	url := cfg.Sentinel.RiskAPIURL
 
 	req, _ := http.NewRequest(
 		"POST",
@@ -80,7 +79,7 @@ func buildChangeSummary(pr *github.PullRequest, files []ChangedFile) string {
 			continue
 		}
 
-		lines := extractSignalLines(f.Patch, 4)
+		lines := extractSignalLines(f.Hunks, 4)
 		if len(lines) == 0 {
 			b.WriteString(": refactor/format\n")
 			continue
@@ -97,11 +96,30 @@ func buildChangeSummary(pr *github.PullRequest, files []ChangedFile) string {
 	return strings.TrimSpace(b.String())
 }
 
-func extractSignalLines(patch string, max int) []string {
+// extractSignalLines returns up to max changed lines, each tagged with its
+// line number and the enclosing function from the hunk header, e.g.
+// "L42 + return nil (in Handle)". Removed lines use the old line number.
+func extractSignalLines(hunks []diff.Hunk, max int) []string {
 	out := []string{}
-	for _, l := range strings.Split(patch, "\n") {
-		if (strings.HasPrefix(l, "+") || strings.HasPrefix(l, "-")) && len(out) < max {
-			out = append(out, truncate(l, 180))
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			if len(out) >= max {
+				return out
+			}
+
+			var line string
+			switch l.Kind {
+			case diff.Added:
+				line = "L" + itoa(l.NewLine) + " + " + strings.TrimSpace(l.Content)
+			case diff.Removed:
+				line = "L" + itoa(l.OldLine) + " - " + strings.TrimSpace(l.Content)
+			default:
+				continue
+			}
+			if h.Function != "" {
+				line += " (in " + h.Function + ")"
+			}
+			out = append(out, truncate(line, 180))
 		}
 	}
 	return out
@@ -119,8 +137,7 @@ func itoa(i int) string {
 }
 
 func getDB() (*sql.DB, error) {
-	connStr := os.Getenv("DATABASE_URL")
-	return sql.Open("postgres", connStr)
+	return sql.Open("postgres", cfg.Database.URL)
 }
 
 func recordSentinelEvent(ev PREvent, summary string) error {
@@ -163,4 +180,108 @@ func recordSentinelEvent(ev PREvent, summary string) error {
 	)
 
 	return err
-}
\ No newline at end of file
+}
+
+// CoChangeHint is a file that historically changes together with a file in
+// the PR but is missing from it.
+type CoChangeHint struct {
+	Changed    string
+	Missing    string
+	Together   int
+	Total      int
+	Confidence float64
+}
+
+const (
+	minHintCoChanges  = 3
+	minHintConfidence = 0.6
+	maxHints          = 8
+)
+
+func loadCoChangeHints(repo string, changed []string) ([]CoChangeHint, error) {
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT file_a, file_b, co_changes, changes_a, changes_b, confidence_ab, confidence_ba
+		FROM file_co_changes
+		WHERE repo = $1
+		  AND co_changes >= $2
+		  AND (file_a = ANY($3) OR file_b = ANY($3))
+	`, repo, minHintCoChanges, pq.Array(changed))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inPR := map[string]bool{}
+	for _, p := range changed {
+		inPR[p] = true
+	}
+
+	best := map[string]CoChangeHint{}
+	for rows.Next() {
+		var a, b string
+		var n, changesA, changesB int
+		var confAB, confBA float64
+		if err := rows.Scan(&a, &b, &n, &changesA, &changesB, &confAB, &confBA); err != nil {
+			return nil, err
+		}
+
+		// Confidence is directional: how often a change to the file in
+		// the PR also touched the missing one.
+		var h CoChangeHint
+		switch {
+		case inPR[a] && !inPR[b]:
+			h = CoChangeHint{Changed: a, Missing: b, Together: n, Total: changesA, Confidence: confAB}
+		case inPR[b] && !inPR[a]:
+			h = CoChangeHint{Changed: b, Missing: a, Together: n, Total: changesB, Confidence: confBA}
+		default:
+			continue
+		}
+		if h.Confidence < minHintConfidence {
+			continue
+		}
+
+		if prev, ok := best[h.Missing]; !ok || h.Confidence > prev.Confidence {
+			best[h.Missing] = h
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]CoChangeHint, 0, len(best))
+	for _, h := range best {
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Confidence != out[j].Confidence {
+			return out[i].Confidence > out[j].Confidence
+		}
+		return out[i].Missing < out[j].Missing
+	})
+	if len(out) > maxHints {
+		out = out[:maxHints]
+	}
+	return out, nil
+}
+
+func formatCoChangeHints(hints []CoChangeHint) string {
+	if len(hints) == 0 {
+		return "None"
+	}
+	var b strings.Builder
+	for _, h := range hints {
+		fmt.Fprintf(&b, "- Changed `%s` but not `%s`; they changed together in %d of %d past changes\n",
+			h.Changed, h.Missing, h.Together, h.Total)
+	}
+	return strings.TrimSpace(b.String())
+}
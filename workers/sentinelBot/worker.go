@@ -6,11 +6,15 @@ import (
 
 	"github.com/google/go-github/v61/github"
 	"golang.org/x/oauth2"
+
+	"codrel-sentinel/workers/shared/depgraph"
+	"codrel-sentinel/workers/shared/diff"
 )
 
 type ChangedFile struct {
 	Path  string
 	Patch string
+	Hunks []diff.Hunk
 }
 
 type RiskRequest struct {
@@ -57,11 +61,30 @@ func HandlePREvent(ctx context.Context, ev PREvent, token string) {
 
 	changedFiles := files
 
-	criticalFiles := selectCriticalFiles(files)
+	var graph *depgraph.Graph
+	if len(files) > 10 {
+		log.Println("🕸️ Building import graph for blast-radius ranking...")
+		branch := pr.GetBase().GetRepo().GetDefaultBranch()
+		if branch == "" {
+			branch = pr.GetBase().GetRef()
+		}
+		graph, err = cachedImportGraph(ctx, client, ev, branch)
+		if err != nil {
+			log.Printf("⚠️ [Warn] Import graph failed, ranking by file type: %v", err)
+		}
+	}
+
+	criticalFiles := selectCriticalFiles(files, graph)
 	log.Printf("🎯 Selected %d critical files for deep analysis", len(criticalFiles))
 	
 	changeSummary := buildChangeSummary(pr, files)
 
+	coChangeHints, err := loadCoChangeHints(ev.Owner+"/"+ev.Repo, extractPaths(files))
+	if err != nil {
+		log.Printf("⚠️ [Warn] Co-change lookup failed: %v", err)
+	}
+	log.Printf("🔗 Found %d co-change hint(s) for files missing from the PR", len(coChangeHints))
+
 	log.Println("💬 Posting 'Running analysis' status comment...")
 	commentID, err := postOrUpdateComment(
 		ctx,
@@ -99,6 +122,7 @@ func HandlePREvent(ctx context.Context, ev PREvent, token string) {
 		changedFiles,
 		diffMap,
 		riskResponse,
+		coChangeHints,
 	)
 	log.Println("✅ Prompt generated successfully")
 
@@ -0,0 +1,12 @@
+package config
+
+// Kafka is the broker connection every worker shares. KAFKA_BROKER is the
+// name the ingestion worker used to read; both are accepted.
+type Kafka struct {
+	Brokers string `yaml:"brokers" env:"KAFKA_BROKERS,KAFKA_BROKER" default:"kafka:29092" required:"true"`
+}
+
+// Database is the Postgres connection of the workers that write results.
+type Database struct {
+	URL string `yaml:"url" env:"DATABASE_URL" required:"true" secret:"true"`
+}
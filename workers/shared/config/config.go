@@ -0,0 +1,265 @@
+// Package config loads a worker's settings into a typed struct. Each field
+// names its YAML key, environment variables and default in struct tags:
+//
+//	Brokers string `yaml:"brokers" env:"KAFKA_BROKERS,KAFKA_BROKER" default:"kafka:29092" required:"true"`
+//
+// Values apply in order: the default, the YAML file named by CONFIG_FILE if
+// set, then the first environment variable in the list that is set. Nested
+// structs are YAML sections. Fields tagged secret:"true" are masked by Dump.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the environment variable that points at the YAML file.
+const FileEnv = "CONFIG_FILE"
+
+// Validator is implemented by configs, or sections of them, with rules that
+// span several fields.
+type Validator interface {
+	Validate() error
+}
+
+// Load fills cfg, a pointer to a struct, and validates it. Every problem is
+// reported at once.
+func Load(cfg any) error {
+	return LoadFile(cfg, os.Getenv(FileEnv))
+}
+
+// LoadFile is Load with an explicit YAML path; an empty path reads no file.
+// Top-level keys the struct does not know are ignored, so one file can hold
+// the sections of several workers; unknown keys inside a section are errors.
+func LoadFile(cfg any, path string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Load needs a pointer to a struct")
+	}
+	root := v.Elem()
+
+	var errs []error
+	walk(root, "", func(key string, f reflect.StructField, fv reflect.Value) {
+		if def, ok := f.Tag.Lookup("default"); ok {
+			if err := set(fv, def); err != nil {
+				errs = append(errs, fmt.Errorf("%s: bad default %q: %w", key, def, err))
+			}
+		}
+	})
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		doc, err := parseYAML(data)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		errs = append(errs, applyFile(root, doc, "", true)...)
+	}
+
+	walk(root, "", func(key string, f reflect.StructField, fv reflect.Value) {
+		for _, name := range envNames(f) {
+			value, ok := os.LookupEnv(name)
+			if !ok || value == "" {
+				continue
+			}
+			if err := set(fv, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			break
+		}
+	})
+
+	walk(root, "", func(key string, f reflect.StructField, fv reflect.Value) {
+		if f.Tag.Get("required") == "true" && fv.IsZero() {
+			hint := key
+			if names := envNames(f); len(names) > 0 {
+				hint += " (" + names[0] + ")"
+			}
+			errs = append(errs, fmt.Errorf("%s is required", hint))
+		}
+	})
+	errs = append(errs, validate(root)...)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+// walk calls fn for every leaf field under v with its dotted YAML key.
+func walk(v reflect.Value, prefix string, fn func(key string, f reflect.StructField, fv reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key := joinKey(prefix, yamlKey(f))
+		if isSection(f.Type) {
+			walk(v.Field(i), key, fn)
+			continue
+		}
+		fn(key, f, v.Field(i))
+	}
+}
+
+func applyFile(v reflect.Value, doc map[string]any, prefix string, top bool) []error {
+	fields := map[string]int{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			fields[yamlKey(t.Field(i))] = i
+		}
+	}
+
+	var errs []error
+	for key, raw := range doc {
+		full := joinKey(prefix, key)
+		i, ok := fields[key]
+		if !ok {
+			if !top {
+				errs = append(errs, fmt.Errorf("%s: unknown key", full))
+			}
+			continue
+		}
+		f, fv := t.Field(i), v.Field(i)
+
+		if isSection(f.Type) {
+			section, ok := raw.(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: expected a section", full))
+				continue
+			}
+			errs = append(errs, applyFile(fv, section, full, false)...)
+			continue
+		}
+
+		var err error
+		switch value := raw.(type) {
+		case string:
+			err = set(fv, value)
+		case []string:
+			if fv.Kind() == reflect.Slice {
+				fv.Set(reflect.ValueOf(append([]string{}, value...)))
+			} else {
+				err = errors.New("expected a single value, got a list")
+			}
+		default:
+			err = errors.New("expected a value, got a section")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", full, err))
+		}
+	}
+	return errs
+}
+
+func validate(v reflect.Value) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() && isSection(t.Field(i).Type) {
+			errs = append(errs, validate(v.Field(i))...)
+		}
+	}
+	if val, ok := v.Addr().Interface().(Validator); ok {
+		if err := val.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses s into a leaf field. Lists are comma-separated; booleans also
+// take on/off and yes/no.
+func set(v reflect.Value, s string) error {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := parseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.CanFloat():
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		list := []string{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+func yamlKey(f reflect.StructField) string {
+	if key := f.Tag.Get("yaml"); key != "" {
+		return key
+	}
+	return strings.ToLower(f.Name)
+}
+
+func envNames(f reflect.StructField) []string {
+	var out []string
+	for _, name := range strings.Split(f.Tag.Get("env"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Kafka  Kafka       `yaml:"kafka"`
+	Worker testSection `yaml:"worker"`
+}
+
+type testSection struct {
+	Topic    string        `yaml:"topic" env:"TEST_TOPIC" default:"events" required:"true"`
+	Workers  int           `yaml:"workers" env:"TEST_WORKERS" default:"2"`
+	Enabled  bool          `yaml:"enabled" env:"TEST_ENABLED" default:"on"`
+	Interval time.Duration `yaml:"interval" env:"TEST_INTERVAL" default:"1m"`
+	Labels   []string      `yaml:"labels" env:"TEST_LABELS"`
+	Password string        `yaml:"password" env:"TEST_PASSWORD" secret:"true"`
+	URL      string        `yaml:"url" env:"TEST_URL" secret:"true"`
+}
+
+func writeFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want testSection
+		err  string
+	}{
+		{
+			name: "defaults",
+			want: testSection{Topic: "events", Workers: 2, Enabled: true, Interval: time.Minute},
+		},
+		{
+			name: "file over defaults",
+			file: "worker:\n  topic: jobs\n  workers: 4\n  enabled: off\n  interval: 30s\n",
+			want: testSection{Topic: "jobs", Workers: 4, Interval: 30 * time.Second},
+		},
+		{
+			name: "env over file",
+			file: "worker:\n  topic: jobs\n",
+			env:  map[string]string{"TEST_TOPIC": "from-env"},
+			want: testSection{Topic: "from-env", Workers: 2, Enabled: true, Interval: time.Minute},
+		},
+		{
+			name: "flow list keeps quoted commas",
+			file: "worker:\n  labels: [\"a, b\", c]\n",
+			want: testSection{Topic: "events", Workers: 2, Enabled: true, Interval: time.Minute, Labels: []string{"a, b", "c"}},
+		},
+		{
+			name: "block list",
+			file: "worker:\n  labels:\n    - a\n    - 'b # not a comment'\n",
+			want: testSection{Topic: "events", Workers: 2, Enabled: true, Interval: time.Minute, Labels: []string{"a", "b # not a comment"}},
+		},
+		{
+			name: "env list",
+			env:  map[string]string{"TEST_LABELS": "a, b,,c"},
+			want: testSection{Topic: "events", Workers: 2, Enabled: true, Interval: time.Minute, Labels: []string{"a", "b", "c"}},
+		},
+		{
+			name: "unknown top-level section ignored",
+			file: "other_worker:\n  anything: 1\n",
+			want: testSection{Topic: "events", Workers: 2, Enabled: true, Interval: time.Minute},
+		},
+		{
+			name: "unknown key in section",
+			file: "worker:\n  topik: jobs\n",
+			err:  "worker.topik: unknown key",
+		},
+		{
+			name: "bad value",
+			env:  map[string]string{"TEST_WORKERS": "many"},
+			err:  "TEST_WORKERS",
+		},
+		{
+			name: "required left empty",
+			file: "worker:\n  topic: \"\"\n",
+			err:  "worker.topic (TEST_TOPIC) is required",
+		},
+		{
+			name: "list where a value belongs",
+			file: "worker:\n  topic: [a, b]\n",
+			err:  "worker.topic: expected a single value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KAFKA_BROKERS", "")
+			t.Setenv("KAFKA_BROKER", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, tt.file)
+			}
+
+			var cfg testConfig
+			err := LoadFile(&cfg, path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Worker, tt.want) {
+				t.Errorf("got %+v\nwant %+v", cfg.Worker, tt.want)
+			}
+			if cfg.Kafka.Brokers != "kafka:29092" {
+				t.Errorf("brokers = %q", cfg.Kafka.Brokers)
+			}
+		})
+	}
+}
+
+func TestLoadFileReportsEveryProblem(t *testing.T) {
+	t.Setenv("TEST_WORKERS", "many")
+	t.Setenv("TEST_INTERVAL", "soon")
+
+	var cfg testConfig
+	err := LoadFile(&cfg, writeFile(t, "worker:\n  topic: \"\"\n"))
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"TEST_WORKERS", "TEST_INTERVAL", "worker.topic"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestDumpMasksSecrets(t *testing.T) {
+	var cfg testConfig
+	t.Setenv("TEST_PASSWORD", "hunter22")
+	t.Setenv("TEST_URL", "postgres://app:hunter22@db:5432/app?sslmode=disable")
+	if err := LoadFile(&cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	out := Dump(&cfg)
+	if strings.Contains(out, "hunter22") {
+		t.Errorf("secret leaked:\n%s", out)
+	}
+	for _, want := range []string{
+		"worker.password = ****",
+		"worker.url = postgres://****@db:5432/app?****",
+		"worker.interval = 1m0s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
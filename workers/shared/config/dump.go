@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const masked = "****"
+
+// Dump renders the effective config one "key = value" line per field, in
+// declaration order, with secret fields masked. It is meant for the startup
+// log.
+func Dump(cfg any) string {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	var b strings.Builder
+	walk(v, "", func(key string, f reflect.StructField, fv reflect.Value) {
+		value := format(fv)
+		if f.Tag.Get("secret") == "true" {
+			value = mask(value)
+		}
+		fmt.Fprintf(&b, "  %s = %s\n", key, value)
+	})
+	return b.String()
+}
+
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// mask hides a secret but keeps an empty value visibly empty. A URL keeps
+// its scheme, host and path so the target can still be checked; credentials
+// and the query are hidden.
+func mask(s string) string {
+	if s == "" {
+		return "(unset)"
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return masked
+	}
+
+	out := u.Scheme + "://"
+	if u.User != nil {
+		out += masked + "@"
+	}
+	out += u.Host + u.Path
+	if u.RawQuery != "" {
+		out += "?" + masked
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML decodes a config file into nested maps whose leaves are the
+// scalars as written, or lists of them, so values go through the same
+// parsing as environment variables. Lists of mappings are not supported.
+func parseYAML(data []byte) (map[string]any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return map[string]any{}, nil
+	}
+
+	v, err := fromNode(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	out, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: expected a mapping at the top level", doc.Content[0].Line)
+	}
+	return out, nil
+}
+
+func fromNode(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return fromNode(n.Alias)
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return "", nil
+		}
+		return n.Value, nil
+	case yaml.SequenceNode:
+		out := make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind == yaml.AliasNode {
+				item = item.Alias
+			}
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: only lists of plain values are supported", item.Line)
+			}
+			out = append(out, item.Value)
+		}
+		return out, nil
+	case yaml.MappingNode:
+		out := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be plain values", key.Line)
+			}
+			v, err := fromNode(value)
+			if err != nil {
+				return nil, err
+			}
+			out[key.Value] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", n.Line)
+}
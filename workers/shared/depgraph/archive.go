@@ -0,0 +1,80 @@
+package depgraph
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxArchiveBytes = 512 << 20
+	maxFileBytes    = 1 << 20
+)
+
+// archiveClient bounds a download even when the caller's context has no
+// deadline.
+var archiveClient = &http.Client{Timeout: 5 * time.Minute}
+
+// LoadArchive reads a gzipped tarball, as served by GitHub's archive
+// endpoint, and adds every relevant file to the graph. The leading
+// "<owner>-<repo>-<sha>/" directory is stripped from entry names.
+func (g *Graph) LoadArchive(r io.Reader) error {
+	gz, err := gzip.NewReader(io.LimitReader(r, maxArchiveBytes))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		_, name, ok := strings.Cut(hdr.Name, "/")
+		if !ok || name == "" {
+			continue
+		}
+		if !Relevant(name) || hdr.Size > maxFileBytes {
+			g.AddPath(name)
+			continue
+		}
+
+		src, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		g.AddFile(name, src)
+	}
+}
+
+// LoadURL downloads a tarball from url and loads it with LoadArchive.
+func (g *Graph) LoadURL(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := archiveClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("archive download: %s", resp.Status)
+	}
+	return g.LoadArchive(resp.Body)
+}
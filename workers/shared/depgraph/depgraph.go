@@ -0,0 +1,458 @@
+// Package depgraph builds a static import graph of a repository and answers
+// blast-radius questions: given the files a change touched, which packages
+// and modules transitively depend on them.
+//
+// Go files are grouped into packages (one node per directory) and imports are
+// resolved through the module paths declared in every go.mod in the tree. TS
+// and JS files are one node each; relative imports and tsconfig/jsconfig
+// "paths" aliases are resolved, bare package imports are ignored.
+package depgraph
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Graph struct {
+	files     map[string]bool
+	goDirs    map[string]bool
+	modules   map[string]string // go.mod dir -> module path
+	tsconfigs map[string]tsConfig
+	imports   map[string][]string // source file -> raw import specs
+
+	deps  map[string]map[string]bool
+	rdeps map[string]map[string]bool
+}
+
+type tsConfig struct {
+	baseURL string
+	paths   map[string][]string
+}
+
+func New() *Graph {
+	return &Graph{
+		files:     map[string]bool{},
+		goDirs:    map[string]bool{},
+		modules:   map[string]string{},
+		tsconfigs: map[string]tsConfig{},
+		imports:   map[string][]string{},
+	}
+}
+
+// Relevant reports whether a file's content is needed to build the graph.
+func Relevant(p string) bool {
+	if skippedDir(p) {
+		return false
+	}
+	base := path.Base(p)
+	switch base {
+	case "go.mod", "tsconfig.json", "jsconfig.json":
+		return true
+	}
+	if strings.HasSuffix(p, "_test.go") || strings.HasSuffix(p, ".d.ts") {
+		return false
+	}
+	return isGo(p) || isScript(p)
+}
+
+func skippedDir(p string) bool {
+	for _, seg := range strings.Split(path.Dir(p), "/") {
+		switch seg {
+		case "node_modules", "vendor", "dist", "build", ".next", "testdata":
+			return true
+		}
+	}
+	return false
+}
+
+func isGo(p string) bool {
+	return strings.HasSuffix(p, ".go")
+}
+
+var scriptExts = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"}
+
+func isScript(p string) bool {
+	for _, ext := range scriptExts {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Size reports how many files the graph knows about.
+func (g *Graph) Size() int {
+	return len(g.files)
+}
+
+// AddPath records that a file exists, so imports of it can be resolved even
+// when its content was not loaded.
+func (g *Graph) AddPath(p string) {
+	g.files[p] = true
+	if isGo(p) && !strings.HasSuffix(p, "_test.go") {
+		g.goDirs[path.Dir(p)] = true
+	}
+}
+
+// AddFile records a file and the imports found in its content.
+func (g *Graph) AddFile(p string, src []byte) {
+	g.AddPath(p)
+	g.deps = nil
+
+	switch base := path.Base(p); {
+	case base == "go.mod":
+		if mod := modulePath(src); mod != "" {
+			g.modules[path.Dir(p)] = mod
+		}
+	case base == "tsconfig.json" || base == "jsconfig.json":
+		if cfg, ok := parseTSConfig(src); ok {
+			g.tsconfigs[path.Dir(p)] = cfg
+		}
+	case isGo(p):
+		g.imports[p] = goImports(p, src)
+	case isScript(p):
+		g.imports[p] = scriptImports(src)
+	}
+}
+
+var moduleRegex = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+
+func modulePath(src []byte) string {
+	if m := moduleRegex.FindSubmatch(src); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+func goImports(p string, src []byte) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), p, src, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(f.Imports))
+	for _, imp := range f.Imports {
+		if v, err := strconv.Unquote(imp.Path.Value); err == nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+var scriptImportRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^\s*(?:import|export)\s[^'";]*?\bfrom\s*['"]([^'"]+)['"]`),
+	regexp.MustCompile(`(?m)^\s*import\s*['"]([^'"]+)['"]`),
+	regexp.MustCompile(`\brequire\(\s*['"]([^'"]+)['"]\s*\)`),
+	regexp.MustCompile(`\bimport\(\s*['"]([^'"]+)['"]\s*\)`),
+}
+
+func scriptImports(src []byte) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, re := range scriptImportRegexes {
+		for _, m := range re.FindAllSubmatch(src, -1) {
+			spec := string(m[1])
+			if !seen[spec] {
+				seen[spec] = true
+				out = append(out, spec)
+			}
+		}
+	}
+	return out
+}
+
+func parseTSConfig(src []byte) (tsConfig, bool) {
+	var raw struct {
+		CompilerOptions struct {
+			BaseURL string              `json:"baseUrl"`
+			Paths   map[string][]string `json:"paths"`
+		} `json:"compilerOptions"`
+	}
+	if err := json.Unmarshal(stripJSONC(src), &raw); err != nil {
+		return tsConfig{}, false
+	}
+	return tsConfig{
+		baseURL: raw.CompilerOptions.BaseURL,
+		paths:   raw.CompilerOptions.Paths,
+	}, true
+}
+
+// stripJSONC removes comments and trailing commas, which tsconfig files allow.
+func stripJSONC(src []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(src) {
+				i++
+				out = append(out, src[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			i += 2
+			for i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Node returns the graph node a file belongs to: its directory for Go files,
+// the file itself for TS/JS, and "" for anything else.
+func (g *Graph) Node(p string) string {
+	switch {
+	case isGo(p):
+		return path.Dir(p)
+	case isScript(p):
+		return p
+	}
+	return ""
+}
+
+// Match maps a path as it appears in logs or stack traces (absolute, or
+// prefixed with a checkout directory) to a known repository file.
+func (g *Graph) Match(p string) string {
+	p = strings.TrimPrefix(path.Clean(strings.ReplaceAll(p, "\\", "/")), "/")
+	for {
+		if g.files[p] {
+			return p
+		}
+		i := strings.Index(p, "/")
+		if i < 0 {
+			return ""
+		}
+		p = p[i+1:]
+	}
+}
+
+// Resolve turns the recorded import specs into edges. It runs on demand and
+// again after further files are added.
+func (g *Graph) Resolve() {
+	g.deps = map[string]map[string]bool{}
+	g.rdeps = map[string]map[string]bool{}
+
+	for file, specs := range g.imports {
+		from := g.Node(file)
+		for _, spec := range specs {
+			var to string
+			if isGo(file) {
+				to = g.resolveGo(spec)
+			} else {
+				to = g.resolveScript(file, spec)
+			}
+			if to == "" || to == from {
+				continue
+			}
+			if g.deps[from] == nil {
+				g.deps[from] = map[string]bool{}
+			}
+			if g.rdeps[to] == nil {
+				g.rdeps[to] = map[string]bool{}
+			}
+			g.deps[from][to] = true
+			g.rdeps[to][from] = true
+		}
+	}
+}
+
+func (g *Graph) resolveGo(spec string) string {
+	best, bestDir := "", ""
+	for dir, mod := range g.modules {
+		if (spec == mod || strings.HasPrefix(spec, mod+"/")) && len(mod) > len(best) {
+			best, bestDir = mod, dir
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	dir := path.Join(bestDir, strings.TrimPrefix(spec, best))
+	if !g.goDirs[dir] {
+		return ""
+	}
+	return dir
+}
+
+func (g *Graph) resolveScript(file, spec string) string {
+	if strings.HasPrefix(spec, ".") {
+		return g.scriptFile(path.Join(path.Dir(file), spec))
+	}
+
+	dir, cfg, ok := g.tsconfigFor(file)
+	if !ok {
+		return ""
+	}
+	base := path.Join(dir, cfg.baseURL)
+
+	for pattern, targets := range cfg.paths {
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		var rest string
+		switch {
+		case !wildcard && spec == pattern:
+		case wildcard && strings.HasPrefix(spec, prefix) && strings.HasSuffix(spec, suffix) &&
+			len(spec) >= len(prefix)+len(suffix):
+			rest = spec[len(prefix) : len(spec)-len(suffix)]
+		default:
+			continue
+		}
+		for _, t := range targets {
+			if hit := g.scriptFile(path.Join(base, strings.Replace(t, "*", rest, 1))); hit != "" {
+				return hit
+			}
+		}
+	}
+
+	if cfg.baseURL != "" {
+		return g.scriptFile(path.Join(base, spec))
+	}
+	return ""
+}
+
+// tsconfigFor returns the nearest tsconfig/jsconfig above file.
+func (g *Graph) tsconfigFor(file string) (string, tsConfig, bool) {
+	dir := path.Dir(file)
+	for {
+		if cfg, ok := g.tsconfigs[dir]; ok {
+			return dir, cfg, true
+		}
+		if dir == "." || dir == "/" {
+			return "", tsConfig{}, false
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// scriptFile applies Node-style resolution: exact file, added extension,
+// then directory index. A ".js" specifier may name a ".ts" source.
+func (g *Graph) scriptFile(p string) string {
+	candidates := []string{p}
+	if ext := path.Ext(p); ext == ".js" || ext == ".jsx" || ext == ".mjs" || ext == ".cjs" {
+		stem := strings.TrimSuffix(p, ext)
+		candidates = append(candidates, stem+".ts", stem+".tsx")
+	}
+	for _, ext := range scriptExts {
+		candidates = append(candidates, p+ext)
+	}
+	for _, ext := range scriptExts {
+		candidates = append(candidates, p+"/index"+ext)
+	}
+
+	for _, c := range candidates {
+		if g.files[c] && isScript(c) {
+			return c
+		}
+	}
+	return ""
+}
+
+type Dependent struct {
+	Node  string `json:"node"`
+	Depth int    `json:"depth"`
+}
+
+// Radius is the set of nodes that transitively import what a change touched.
+type Radius struct {
+	Nodes      []string    `json:"nodes"`
+	Direct     int         `json:"direct"`
+	Transitive int         `json:"transitive"`
+	Dependents []Dependent `json:"dependents,omitempty"`
+}
+
+// Radius walks reverse import edges from the nodes of paths. Dependents are
+// ordered nearest first and capped at limit; the counts are not capped. It
+// returns nil when none of the paths are part of the graph.
+func (g *Graph) Radius(paths []string, limit int) *Radius {
+	if g.deps == nil {
+		g.Resolve()
+	}
+
+	depth := map[string]int{}
+	var queue, nodes []string
+	for _, p := range paths {
+		n := g.Node(p)
+		if n == "" {
+			continue
+		}
+		if isScript(p) && !g.files[p] {
+			continue
+		}
+		if isGo(p) && !g.goDirs[n] {
+			continue
+		}
+		if _, ok := depth[n]; ok {
+			continue
+		}
+		depth[n] = 0
+		queue = append(queue, n)
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	sort.Strings(nodes)
+
+	var deps []Dependent
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		next := make([]string, 0, len(g.rdeps[n]))
+		for d := range g.rdeps[n] {
+			next = append(next, d)
+		}
+		sort.Strings(next)
+
+		for _, d := range next {
+			if _, ok := depth[d]; ok {
+				continue
+			}
+			depth[d] = depth[n] + 1
+			deps = append(deps, Dependent{Node: d, Depth: depth[d]})
+			queue = append(queue, d)
+		}
+	}
+
+	r := &Radius{Nodes: nodes, Transitive: len(deps)}
+	for _, d := range deps {
+		if d.Depth == 1 {
+			r.Direct++
+		}
+	}
+	if len(deps) > limit {
+		deps = deps[:limit]
+	}
+	r.Dependents = deps
+	return r
+}
@@ -0,0 +1,310 @@
+// Package diff parses unified diffs into files, hunks and numbered lines.
+//
+// It accepts both full `git diff` output (with "diff --git" headers, as
+// returned by the raw PR and commit endpoints) and the per-file "patch"
+// fragments GitHub returns from the list-files endpoints, which start
+// directly at the first "@@" hunk header.
+package diff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type LineKind string
+
+const (
+	Context LineKind = "context"
+	Added   LineKind = "added"
+	Removed LineKind = "removed"
+)
+
+type Line struct {
+	Kind    LineKind `json:"kind"`
+	Content string   `json:"content"`
+	OldLine int      `json:"old_line,omitempty"`
+	NewLine int      `json:"new_line,omitempty"`
+}
+
+type Hunk struct {
+	OldStart int `json:"old_start"`
+	OldLines int `json:"old_lines"`
+	NewStart int `json:"new_start"`
+	NewLines int `json:"new_lines"`
+
+	// Section is the text git prints after the second "@@", usually the
+	// enclosing function signature; Function is the name parsed from it.
+	Section  string `json:"section,omitempty"`
+	Function string `json:"function,omitempty"`
+
+	Lines []Line `json:"lines"`
+}
+
+type Status string
+
+const (
+	Modified Status = "modified"
+	Created  Status = "added"
+	Deleted  Status = "deleted"
+	Renamed  Status = "renamed"
+	Copied   Status = "copied"
+)
+
+type File struct {
+	OldPath    string `json:"old_path,omitempty"`
+	NewPath    string `json:"new_path"`
+	Status     Status `json:"status"`
+	Binary     bool   `json:"binary,omitempty"`
+	Similarity int    `json:"similarity,omitempty"`
+	Hunks      []Hunk `json:"hunks,omitempty"`
+}
+
+// Path returns the file's path after the change, or before it for deletions.
+func (f File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+func (f File) Stats() (additions, deletions int) {
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case Added:
+				additions++
+			case Removed:
+				deletions++
+			}
+		}
+	}
+	return additions, deletions
+}
+
+var (
+	hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+	gitHeaderRegex  = regexp.MustCompile(`^diff --git "?a/(.+?)"? "?b/(.+?)"?$`)
+)
+
+// Parse parses a full multi-file unified diff.
+func Parse(text string) []File {
+	var (
+		files []File
+		cur   *File
+	)
+
+	flush := func() {
+		if cur != nil {
+			files = append(files, *cur)
+			cur = nil
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			flush()
+			cur = &File{Status: Modified}
+			if m := gitHeaderRegex.FindStringSubmatch(l); m != nil {
+				cur.OldPath, cur.NewPath = m[1], m[2]
+			}
+
+		case cur == nil:
+			// Preamble before the first file (commit message, stats).
+			if strings.HasPrefix(l, "--- ") || strings.HasPrefix(l, "@@ ") {
+				cur = &File{Status: Modified}
+				i--
+			}
+
+		case strings.HasPrefix(l, "new file mode"):
+			cur.Status = Created
+		case strings.HasPrefix(l, "deleted file mode"):
+			cur.Status = Deleted
+		case strings.HasPrefix(l, "rename from "):
+			cur.Status = Renamed
+			cur.OldPath = strings.TrimPrefix(l, "rename from ")
+		case strings.HasPrefix(l, "rename to "):
+			cur.NewPath = strings.TrimPrefix(l, "rename to ")
+		case strings.HasPrefix(l, "copy from "):
+			cur.Status = Copied
+			cur.OldPath = strings.TrimPrefix(l, "copy from ")
+		case strings.HasPrefix(l, "copy to "):
+			cur.NewPath = strings.TrimPrefix(l, "copy to ")
+		case strings.HasPrefix(l, "similarity index "):
+			cur.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(l, "similarity index "), "%"))
+		case strings.HasPrefix(l, "Binary files ") || l == "GIT binary patch":
+			cur.Binary = true
+
+		case strings.HasPrefix(l, "--- "):
+			if p := stripPrefix(strings.TrimPrefix(l, "--- ")); p != "" {
+				cur.OldPath = p
+			} else {
+				cur.Status = Created
+			}
+		case strings.HasPrefix(l, "+++ "):
+			if p := stripPrefix(strings.TrimPrefix(l, "+++ ")); p != "" {
+				cur.NewPath = p
+			} else {
+				cur.Status = Deleted
+			}
+
+		case strings.HasPrefix(l, "@@ "):
+			var h Hunk
+			var ok bool
+			if h, i, ok = parseHunk(lines, i); ok {
+				cur.Hunks = append(cur.Hunks, h)
+			}
+		}
+	}
+	flush()
+
+	for i := range files {
+		if files[i].Status == Deleted {
+			files[i].NewPath = ""
+		}
+		if files[i].Status == Created {
+			files[i].OldPath = ""
+		}
+	}
+
+	return files
+}
+
+// ParseHunks parses a single-file patch fragment that starts at the first
+// hunk header, as found in GitHub's per-file "patch" field.
+func ParseHunks(patch string) []Hunk {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "@@ ") {
+			continue
+		}
+		var h Hunk
+		var ok bool
+		if h, i, ok = parseHunk(lines, i); ok {
+			hunks = append(hunks, h)
+		}
+	}
+	return hunks
+}
+
+// parseHunk parses the hunk whose header is lines[i] and returns it with the
+// index of its last line. ok is false when the header is malformed; the lines
+// under it are then skipped by the callers as they look for the next header.
+func parseHunk(lines []string, i int) (h Hunk, last int, ok bool) {
+	m := hunkHeaderRegex.FindStringSubmatch(lines[i])
+	if m == nil {
+		return h, i, false
+	}
+	h.OldStart, _ = strconv.Atoi(m[1])
+	h.OldLines = atoiDefault(m[2], 1)
+	h.NewStart, _ = strconv.Atoi(m[3])
+	h.NewLines = atoiDefault(m[4], 1)
+	h.Section = strings.TrimSpace(m[5])
+	h.Function = FunctionName(h.Section)
+
+	oldNo, newNo := h.OldStart, h.NewStart
+	oldLeft, newLeft := h.OldLines, h.NewLines
+
+	for i+1 < len(lines) && (oldLeft > 0 || newLeft > 0) {
+		l := lines[i+1]
+		if l == "" {
+			// Trailing newline of the whole diff, or a blank context line
+			// whose leading space was stripped.
+			if oldLeft == 0 || newLeft == 0 {
+				break
+			}
+			l = " "
+		}
+
+		switch l[0] {
+		case '+':
+			h.Lines = append(h.Lines, Line{Kind: Added, Content: l[1:], NewLine: newNo})
+			newNo++
+			newLeft--
+		case '-':
+			h.Lines = append(h.Lines, Line{Kind: Removed, Content: l[1:], OldLine: oldNo})
+			oldNo++
+			oldLeft--
+		case ' ':
+			h.Lines = append(h.Lines, Line{Kind: Context, Content: l[1:], OldLine: oldNo, NewLine: newNo})
+			oldNo++
+			newNo++
+			oldLeft--
+			newLeft--
+		case '\\':
+			// "\ No newline at end of file"
+		default:
+			return h, i, true
+		}
+		i++
+	}
+
+	if i+1 < len(lines) && strings.HasPrefix(lines[i+1], `\`) {
+		i++
+	}
+	return h, i, true
+}
+
+func stripPrefix(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), `"`)
+	if p == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		return p[2:]
+	}
+	return p
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+var functionPatterns = []*regexp.Regexp{
+	// Go: func Name(, func (r *T) Name(
+	regexp.MustCompile(`\bfunc\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^type\s+([A-Za-z_]\w*)`),
+	// Python / Ruby
+	regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+	// JS / TS
+	regexp.MustCompile(`\bfunction\s*\*?\s*([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`\b(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s*)?(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*=>`),
+	regexp.MustCompile(`\b(?:class|interface|struct|enum|trait|impl)\s+([A-Za-z_$][\w$]*)`),
+	// Rust
+	regexp.MustCompile(`\bfn\s+([A-Za-z_]\w*)`),
+	// Java / C# / TS methods: modifiers, return type, name(
+	regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|async|override|final|virtual|abstract)\s+)+(?:[\w<>\[\],.?]+\s+)?([A-Za-z_$][\w$]*)\s*\(`),
+	// C-like: type name(
+	regexp.MustCompile(`^\s*[A-Za-z_][\w\s\*&:<>,]*?\b([A-Za-z_]\w*)\s*\(`),
+}
+
+// FunctionName extracts the function, method or type name from a hunk
+// section header. It returns "" if none of the known shapes match.
+func FunctionName(section string) string {
+	if section == "" {
+		return ""
+	}
+	for _, re := range functionPatterns {
+		if m := re.FindStringSubmatch(section); m != nil {
+			switch m[1] {
+			case "if", "for", "while", "switch", "return", "catch":
+				continue
+			}
+			return m[1]
+		}
+	}
+	return ""
+}
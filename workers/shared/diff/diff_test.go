@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+const multiFile = `From 1234 Mon Sep 17 00:00:00 2001
+Subject: [PATCH] change things
+
+diff --git a/pkg/server.go b/pkg/server.go
+index 1111111..2222222 100644
+--- a/pkg/server.go
++++ b/pkg/server.go
+@@ -1,3 +1,4 @@ package pkg
+ import "fmt"
++import "log"
+ 
+ var x = 1
+@@ -10,4 +11,3 @@ func (s *Server) Start() error {
+ 	a := 1
+-	b := 2
+-	c := 3
++	c := 4
+ 	return nil
+diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+diff --git a/docs/guide.md b/docs/manual.md
+similarity index 90%
+rename from docs/guide.md
+rename to docs/manual.md
+index 3333333..4444444 100644
+--- a/docs/guide.md
++++ b/docs/manual.md
+@@ -2 +2 @@
+-old title
++new title
+diff --git a/logo.png b/logo.png
+new file mode 100644
+index 0000000..5555555
+Binary files /dev/null and b/logo.png differ
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 6666666..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package gone
+-func F() {}
+`
+
+func TestParse(t *testing.T) {
+	files := Parse(multiFile)
+	if len(files) != 5 {
+		t.Fatalf("got %d files, want 5", len(files))
+	}
+
+	type summary struct {
+		Old, New   string
+		Status     Status
+		Binary     bool
+		Similarity int
+		Hunks      int
+		Add, Del   int
+	}
+	var got []summary
+	for _, f := range files {
+		add, del := f.Stats()
+		got = append(got, summary{f.OldPath, f.NewPath, f.Status, f.Binary, f.Similarity, len(f.Hunks), add, del})
+	}
+	want := []summary{
+		{"pkg/server.go", "pkg/server.go", Modified, false, 0, 2, 2, 2},
+		{"old.txt", "new.txt", Renamed, false, 100, 0, 0, 0},
+		{"docs/guide.md", "docs/manual.md", Renamed, false, 90, 1, 1, 1},
+		{"", "logo.png", Created, true, 0, 0, 0, 0},
+		{"gone.go", "", Deleted, false, 0, 1, 0, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files:\n got  %+v\n want %+v", got, want)
+	}
+	if p := files[4].Path(); p != "gone.go" {
+		t.Errorf("deleted file Path() = %q", p)
+	}
+
+	h := files[0].Hunks[1]
+	if h.OldStart != 10 || h.OldLines != 4 || h.NewStart != 11 || h.NewLines != 3 {
+		t.Errorf("hunk range = %+v", h)
+	}
+	if h.Section != "func (s *Server) Start() error {" || h.Function != "Start" {
+		t.Errorf("section %q, function %q", h.Section, h.Function)
+	}
+	wantLines := []Line{
+		{Kind: Context, Content: "\ta := 1", OldLine: 10, NewLine: 11},
+		{Kind: Removed, Content: "\tb := 2", OldLine: 11},
+		{Kind: Removed, Content: "\tc := 3", OldLine: 12},
+		{Kind: Added, Content: "\tc := 4", NewLine: 12},
+		{Kind: Context, Content: "\treturn nil", OldLine: 13, NewLine: 13},
+	}
+	if !reflect.DeepEqual(h.Lines, wantLines) {
+		t.Errorf("lines:\n got  %+v\n want %+v", h.Lines, wantLines)
+	}
+
+	// A blank context line whose leading space was stripped still counts.
+	if l := files[0].Hunks[0].Lines[2]; l.Kind != Context || l.OldLine != 2 || l.NewLine != 3 {
+		t.Errorf("blank context line = %+v", l)
+	}
+}
+
+func TestParseHunks(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  []Hunk
+	}{
+		{
+			name:  "no newline at end of either side",
+			patch: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+			want: []Hunk{{
+				OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2,
+				Lines: []Line{
+					{Kind: Context, Content: "a", OldLine: 1, NewLine: 1},
+					{Kind: Removed, Content: "b", OldLine: 2},
+					{Kind: Added, Content: "c", NewLine: 2},
+				},
+			}},
+		},
+		{
+			name:  "counts default to one",
+			patch: "@@ -5 +5 @@ def handler(event):\n-x\n+y\n",
+			want: []Hunk{{
+				OldStart: 5, OldLines: 1, NewStart: 5, NewLines: 1,
+				Section: "def handler(event):", Function: "handler",
+				Lines: []Line{
+					{Kind: Removed, Content: "x", OldLine: 5},
+					{Kind: Added, Content: "y", NewLine: 5},
+				},
+			}},
+		},
+		{
+			name:  "two hunks",
+			patch: "@@ -1 +1,2 @@\n a\n+b\n@@ -9,0 +10 @@\n+z",
+			want: []Hunk{
+				{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 2, Lines: []Line{
+					{Kind: Context, Content: "a", OldLine: 1, NewLine: 1},
+					{Kind: Added, Content: "b", NewLine: 2},
+				}},
+				{OldStart: 9, OldLines: 0, NewStart: 10, NewLines: 1, Lines: []Line{
+					{Kind: Added, Content: "z", NewLine: 10},
+				}},
+			},
+		},
+		{
+			name:  "no hunk header",
+			patch: "+orphan line\n-another\n",
+		},
+		{
+			name:  "malformed hunk header skipped",
+			patch: "@@ -x +y @@\n+lost\n@@ -1 +1 @@\n-a\n+b",
+			want: []Hunk{{
+				OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1,
+				Lines: []Line{
+					{Kind: Removed, Content: "a", OldLine: 1},
+					{Kind: Added, Content: "b", NewLine: 1},
+				},
+			}},
+		},
+		{
+			name:  "empty patch",
+			patch: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHunks(tt.patch); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHunks:\n got  %+v\n want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWithoutGitHeader(t *testing.T) {
+	files := Parse("--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n")
+	if len(files) != 1 || files[0].NewPath != "x.go" || len(files[0].Hunks) != 1 {
+		t.Fatalf("files = %+v", files)
+	}
+
+	if files := Parse("just a commit message\nwith no diff\n"); len(files) != 0 {
+		t.Errorf("text without a diff parsed as %+v", files)
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	tests := []struct {
+		section string
+		want    string
+	}{
+		{"func Serve(addr string) error {", "Serve"},
+		{"func (s *Server) Start() error {", "Start"},
+		{"type Config struct {", "Config"},
+		{"async def fetch(url):", "fetch"},
+		{"export function render(props) {", "render"},
+		{"const load = async (id) => {", "load"},
+		{"class Parser {", "Parser"},
+		{"pub fn parse(input: &str) -> Result<()> {", "parse"},
+		{"public static void main(String[] args) {", "main"},
+		{"int compute(int a, int b)", "compute"},
+		{"if (ready) {", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FunctionName(tt.section); got != tt.want {
+			t.Errorf("FunctionName(%q) = %q, want %q", tt.section, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeAll(t *testing.T) {
+	got := SummarizeAll(multiFile)
+	if len(got) != 5 {
+		t.Fatalf("got %d summaries", len(got))
+	}
+
+	s := got[0]
+	want := FileSummary{
+		Path:      "pkg/server.go",
+		Status:    Modified,
+		Additions: 2,
+		Deletions: 2,
+		Added:     []Range{{2, 2}, {12, 12}},
+		Removed:   []Range{{11, 12}},
+		Functions: []string{"Start"},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("summary:\n got  %+v\n want %+v", s, want)
+	}
+	if got[2].OldPath != "docs/guide.md" || got[1].OldPath != "old.txt" {
+		t.Errorf("renames lost their old path: %+v %+v", got[1], got[2])
+	}
+	if got[0].OldPath != "" {
+		t.Errorf("unrenamed file has old path %q", got[0].OldPath)
+	}
+	if SummarizeAll("") != nil {
+		t.Error("empty diff summarized")
+	}
+}
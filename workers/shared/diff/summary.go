@@ -0,0 +1,115 @@
+package diff
+
+import "sort"
+
+// Range is an inclusive span of line numbers.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// FileSummary is the compact form of a File attached to payloads: where the
+// change landed, without the line contents.
+type FileSummary struct {
+	Path      string   `json:"path"`
+	OldPath   string   `json:"old_path,omitempty"`
+	Status    Status   `json:"status"`
+	Binary    bool     `json:"binary,omitempty"`
+	Additions int      `json:"additions"`
+	Deletions int      `json:"deletions"`
+	Added     []Range  `json:"added,omitempty"`
+	Removed   []Range  `json:"removed,omitempty"`
+	Functions []string `json:"functions,omitempty"`
+}
+
+// AddedRanges returns the new-side line ranges of added lines.
+func AddedRanges(hunks []Hunk) []Range {
+	var lines []int
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			if l.Kind == Added {
+				lines = append(lines, l.NewLine)
+			}
+		}
+	}
+	return toRanges(lines)
+}
+
+// RemovedRanges returns the old-side line ranges of removed lines.
+func RemovedRanges(hunks []Hunk) []Range {
+	var lines []int
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			if l.Kind == Removed {
+				lines = append(lines, l.OldLine)
+			}
+		}
+	}
+	return toRanges(lines)
+}
+
+// Functions returns the distinct enclosing functions named in hunk headers,
+// in order of first appearance.
+func Functions(hunks []Hunk) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, h := range hunks {
+		if h.Function == "" || seen[h.Function] {
+			continue
+		}
+		seen[h.Function] = true
+		out = append(out, h.Function)
+	}
+	return out
+}
+
+func Summarize(f File) FileSummary {
+	add, del := f.Stats()
+	s := FileSummary{
+		Path:      f.Path(),
+		Status:    f.Status,
+		Binary:    f.Binary,
+		Additions: add,
+		Deletions: del,
+		Added:     AddedRanges(f.Hunks),
+		Removed:   RemovedRanges(f.Hunks),
+		Functions: Functions(f.Hunks),
+	}
+	if f.OldPath != "" && f.OldPath != f.NewPath {
+		s.OldPath = f.OldPath
+	}
+	return s
+}
+
+// SummarizeAll parses a full diff and summarises each file in it.
+func SummarizeAll(text string) []FileSummary {
+	files := Parse(text)
+	if len(files) == 0 {
+		return nil
+	}
+	out := make([]FileSummary, 0, len(files))
+	for _, f := range files {
+		out = append(out, Summarize(f))
+	}
+	return out
+}
+
+func toRanges(lines []int) []Range {
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Ints(lines)
+
+	out := []Range{{Start: lines[0], End: lines[0]}}
+	for _, n := range lines[1:] {
+		last := &out[len(out)-1]
+		if n <= last.End+1 {
+			if n > last.End {
+				last.End = n
+			}
+			continue
+		}
+		out = append(out, Range{Start: n, End: n})
+	}
+	return out
+}
@@ -0,0 +1,327 @@
+// Package symbols extracts top-level Go declarations with go/parser and
+// compares two versions of a file to report which functions, methods, types
+// and exported identifiers were added, removed or changed.
+package symbols
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+type Kind string
+
+const (
+	Func   Kind = "func"
+	Method Kind = "method"
+	Type   Kind = "type"
+	Var    Kind = "var"
+	Const  Kind = "const"
+)
+
+type Symbol struct {
+	// Name is the declared name; methods are qualified with their receiver
+	// type, e.g. "Server.Start".
+	Name      string `json:"name"`
+	Kind      Kind   `json:"kind"`
+	Exported  bool   `json:"exported"`
+	Signature string `json:"signature"`
+	Line      int    `json:"line"`
+	EndLine   int    `json:"end_line"`
+
+	body string
+	node ast.Node
+	fset *token.FileSet
+}
+
+type ChangeKind string
+
+const (
+	Added            ChangeKind = "added"
+	Removed          ChangeKind = "removed"
+	SignatureChanged ChangeKind = "signature_changed"
+	BodyChanged      ChangeKind = "body_changed"
+)
+
+// Change is one symbol that differs between two versions of a file. Breaking
+// is set when the change can break callers outside the package.
+type Change struct {
+	File     string     `json:"file"`
+	Name     string     `json:"name"`
+	Kind     Kind       `json:"kind"`
+	Change   ChangeKind `json:"change"`
+	Exported bool       `json:"exported"`
+	Breaking bool       `json:"breaking,omitempty"`
+	Before   string     `json:"before,omitempty"`
+	After    string     `json:"after,omitempty"`
+	Line     int        `json:"line,omitempty"`
+}
+
+// Extract parses src and returns its top-level symbols in source order.
+// Unexported vars and consts, init functions and blank identifiers are
+// skipped.
+func Extract(filename string, src []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Symbol
+	add := func(s Symbol, from, to token.Pos) {
+		s.Line = fset.Position(from).Line
+		s.EndLine = fset.Position(to).Line
+		s.fset = fset
+		out = append(out, s)
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name.Name == "init" || d.Name.Name == "_" {
+				continue
+			}
+
+			s := Symbol{
+				Name:     d.Name.Name,
+				Kind:     Func,
+				Exported: d.Name.IsExported(),
+				node:     d,
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				s.Name = recv + "." + d.Name.Name
+				s.Kind = Method
+				s.Exported = s.Exported && ast.IsExported(recv)
+			}
+
+			sig := *d
+			sig.Doc = nil
+			sig.Body = nil
+			s.Signature = render(fset, &sig)
+			if d.Body != nil {
+				s.body = render(fset, d.Body)
+			}
+			add(s, d.Pos(), d.End())
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					if sp.Name.Name == "_" {
+						continue
+					}
+					assign := ""
+					if sp.Assign.IsValid() {
+						assign = "= "
+					}
+					add(Symbol{
+						Name:      sp.Name.Name,
+						Kind:      Type,
+						Exported:  sp.Name.IsExported(),
+						Signature: "type " + sp.Name.Name + typeParams(fset, sp) + " " + assign + render(fset, sp.Type),
+						node:      sp.Type,
+					}, sp.Pos(), sp.End())
+
+				case *ast.ValueSpec:
+					kind := Var
+					if d.Tok == token.CONST {
+						kind = Const
+					}
+					for i, name := range sp.Names {
+						if !name.IsExported() {
+							continue
+						}
+						s := Symbol{
+							Name:      name.Name,
+							Kind:      kind,
+							Exported:  true,
+							Signature: string(kind) + " " + name.Name,
+						}
+						if sp.Type != nil {
+							s.Signature += " " + render(fset, sp.Type)
+						}
+						if i < len(sp.Values) {
+							s.body = render(fset, sp.Values[i])
+						}
+						add(s, sp.Pos(), sp.End())
+					}
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// Compare reports the symbol changes from before to after. A nil side means
+// the file did not exist, so every symbol on the other side is added or
+// removed. Results are ordered by line, removals last.
+func Compare(filename string, before, after []byte) ([]Change, error) {
+	var old, cur []Symbol
+	var err error
+
+	if before != nil {
+		if old, err = Extract(filename, before); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if cur, err = Extract(filename, after); err != nil {
+			return nil, err
+		}
+	}
+
+	oldByName := make(map[string]Symbol, len(old))
+	for _, s := range old {
+		oldByName[s.Name] = s
+	}
+
+	var out []Change
+	seen := map[string]bool{}
+
+	for _, s := range cur {
+		seen[s.Name] = true
+		c := Change{
+			File:     filename,
+			Name:     s.Name,
+			Kind:     s.Kind,
+			Exported: s.Exported,
+			After:    s.Signature,
+			Line:     s.Line,
+		}
+
+		prev, ok := oldByName[s.Name]
+		switch {
+		case !ok:
+			c.Change = Added
+		case prev.Signature != s.Signature || prev.Kind != s.Kind:
+			c.Change = SignatureChanged
+			c.Before = prev.Signature
+			c.Breaking = prev.Exported && breaks(prev, s)
+		case prev.body != s.body:
+			c.Change = BodyChanged
+			c.Before = prev.Signature
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+
+	for _, s := range old {
+		if seen[s.Name] {
+			continue
+		}
+		out = append(out, Change{
+			File:     filename,
+			Name:     s.Name,
+			Kind:     s.Kind,
+			Change:   Removed,
+			Exported: s.Exported,
+			Breaking: s.Exported,
+			Before:   s.Signature,
+			Line:     s.Line,
+		})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		ri, rj := out[i].Change == Removed, out[j].Change == Removed
+		if ri != rj {
+			return rj
+		}
+		return out[i].Line < out[j].Line
+	})
+
+	return out, nil
+}
+
+// breaks decides whether a signature change of an exported symbol is an API
+// break. Growing a struct with new fields is the one common change that is
+// not; everything else (parameters, results, interface method sets, value
+// types) is treated as breaking.
+func breaks(before, after Symbol) bool {
+	if before.Kind != Type || after.Kind != Type {
+		return true
+	}
+
+	bs, ok1 := before.node.(*ast.StructType)
+	as, ok2 := after.node.(*ast.StructType)
+	if !ok1 || !ok2 {
+		return true
+	}
+
+	fields := func(fset *token.FileSet, st *ast.StructType) map[string]string {
+		out := map[string]string{}
+		for _, f := range st.Fields.List {
+			typ := render(fset, f.Type)
+			if len(f.Names) == 0 {
+				name := receiverName(f.Type)
+				out[name] = typ
+				continue
+			}
+			for _, n := range f.Names {
+				out[n.Name] = typ
+			}
+		}
+		return out
+	}
+
+	cur := fields(after.fset, as)
+	for name, typ := range fields(before.fset, bs) {
+		if !ast.IsExported(name) {
+			continue
+		}
+		if t, ok := cur[name]; !ok || t != typ {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverName returns the base type name of a receiver or embedded field,
+// stripping pointers, packages and type parameters.
+func receiverName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.SelectorExpr:
+			return t.Sel.Name
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func typeParams(fset *token.FileSet, sp *ast.TypeSpec) string {
+	if sp.TypeParams == nil || len(sp.TypeParams.List) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, f := range sp.TypeParams.List {
+		var names []string
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+		parts = append(parts, strings.Join(names, ", ")+" "+render(fset, f.Type))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func render(fset *token.FileSet, node ast.Node) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, node); err != nil {
+		return ""
+	}
+	return b.String()
+}
@@ -0,0 +1,208 @@
+package symbols
+
+import (
+	"reflect"
+	"testing"
+)
+
+const before = `package api
+
+import "context"
+
+// Server serves requests.
+type Server struct {
+	Addr string
+	Port int
+	hidden bool
+}
+
+type Handler interface {
+	Handle(ctx context.Context) error
+}
+
+const Version = "1"
+
+var internal = 1
+
+func init() {}
+
+func (s *Server) Start() error { return nil }
+
+func Serve(addr string) error { return nil }
+
+func helper() int { return 1 }
+
+func Deprecated() {}
+`
+
+const after = `package api
+
+import "context"
+
+// Server serves requests, now with a timeout.
+type Server struct {
+	Addr    string
+	Port    int
+	Timeout int
+}
+
+type Handler interface {
+	Handle(ctx context.Context) error
+	Close() error
+}
+
+const Version = "2"
+
+var internal = 2
+
+func init() { println("x") }
+
+func (s *Server) Start() error {
+	return serve(s.Addr)
+}
+
+func Serve(addr string, port int) error { return nil }
+
+func helper() int { return 1 }
+
+func NewServer() *Server { return &Server{} }
+`
+
+func TestCompare(t *testing.T) {
+	changes, err := Compare("api/server.go", []byte(before), []byte(after))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type got struct {
+		Name     string
+		Kind     Kind
+		Change   ChangeKind
+		Breaking bool
+	}
+	var out []got
+	for _, c := range changes {
+		if c.File != "api/server.go" {
+			t.Errorf("%s: file = %q", c.Name, c.File)
+		}
+		out = append(out, got{c.Name, c.Kind, c.Change, c.Breaking})
+	}
+
+	want := []got{
+		// Adding a field to a struct does not break callers.
+		{"Server", Type, SignatureChanged, false},
+		// Growing an interface does.
+		{"Handler", Type, SignatureChanged, true},
+		{"Version", Const, BodyChanged, false},
+		{"Server.Start", Method, BodyChanged, false},
+		{"Serve", Func, SignatureChanged, true},
+		{"NewServer", Func, Added, false},
+		{"Deprecated", Func, Removed, true},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("changes:\n got  %+v\n want %+v", out, want)
+	}
+}
+
+func TestCompareBreakingStructChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   bool
+	}{
+		{"field added", "type T struct{ A int }", "type T struct{ A int; B int }", false},
+		{"field removed", "type T struct{ A int; B int }", "type T struct{ A int }", true},
+		{"field retyped", "type T struct{ A int }", "type T struct{ A string }", true},
+		{"unexported field removed", "type T struct{ A int; b int }", "type T struct{ A int }", false},
+		{"struct to alias", "type T struct{ A int }", "type T = int", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Compare("t.go", []byte("package p\n"+tt.before), []byte("package p\n"+tt.after))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != 1 || changes[0].Change != SignatureChanged {
+				t.Fatalf("changes = %+v, want one signature change", changes)
+			}
+			if changes[0].Breaking != tt.want {
+				t.Errorf("breaking = %v, want %v", changes[0].Breaking, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareNewAndDeletedFiles(t *testing.T) {
+	src := []byte("package p\n\nfunc A() {}\n\ntype B struct{}\n")
+
+	added, err := Compare("p.go", nil, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range added {
+		if c.Change != Added || c.Breaking {
+			t.Errorf("new file: %+v", c)
+		}
+	}
+
+	removed, err := Compare("p.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range removed {
+		if c.Change != Removed || !c.Breaking {
+			t.Errorf("deleted file: %+v", c)
+		}
+	}
+	if len(added) != 2 || len(removed) != 2 {
+		t.Errorf("got %d added and %d removed, want 2 each", len(added), len(removed))
+	}
+}
+
+func TestCompareUnchangedAndInvalid(t *testing.T) {
+	src := []byte("package p\n\n// A does nothing.\nfunc A() {}\n")
+	moved := []byte("package p\n\n\n// A does nothing at all.\nfunc A() {}\n")
+	if changes, err := Compare("p.go", src, moved); err != nil || len(changes) != 0 {
+		t.Errorf("doc and position changes reported: %+v, %v", changes, err)
+	}
+
+	if _, err := Compare("p.go", src, []byte("package p\nfunc (")); err == nil {
+		t.Error("parse error not reported")
+	}
+}
+
+func TestExtractNames(t *testing.T) {
+	src := []byte(`package p
+
+type List[T any] struct{}
+
+func (l *List[T]) Push(v T) {}
+
+func (l List[T]) len() int { return 0 }
+
+var (
+	Exported, hidden = 1, 2
+)
+
+func _() {}
+`)
+	syms, err := Extract("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	exported := map[string]bool{}
+	for _, s := range syms {
+		names = append(names, s.Name)
+		exported[s.Name] = s.Exported
+	}
+	want := []string{"List", "List.Push", "List.len", "Exported"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if !exported["List.Push"] || exported["List.len"] {
+		t.Errorf("exported = %v", exported)
+	}
+}